@@ -2,7 +2,10 @@ package aerr
 
 import (
 	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Error is the immutable error value produced by *Builder.Err and
@@ -18,11 +21,55 @@ type Error struct {
 	attrs []attr
 	pcs   []uintptr
 
+	// truncated counts the frames the capture depth cut off below pcs.
+	truncated int
+
+	// remote holds a stack trace rendered by another process, restored by
+	// UnmarshalJSON. It stands in for pcs when those are empty.
+	remote []string
+
+	// sites is the return trace recorded under SetReturnTrace, origin
+	// first; remoteSites holds the part decoded from another process,
+	// which precedes it.
+	sites       []uintptr
+	remoteSites []string
+
+	// layerStacks holds the stacks outer layers captured under
+	// StackPerLayer, innermost first; remoteLayers holds those decoded
+	// from another process, which precede them.
+	layerStacks  []layerStack
+	remoteLayers []StackSection
+
+	// diag is the snapshot attached with Diagnostics, inherited like the
+	// stack.
+	diag *Diagnostics
+
+	// retryable and retryAfter classify this layer only; IsRetryable and
+	// RetryAfter walk the chain rather than inheriting them.
+	retryable  bool
+	retryAfter time.Duration
+
+	// own records what this layer's builder contributed before the chain
+	// was flattened into the fields above; see Layers.
+	own layerState
+
 	// traces caches the rendered stack so repeated logging of the same
 	// error symbolizes the PCs only once. Guarded by traceOnce, which
 	// keeps the lazy render safe under concurrent LogValue calls.
 	traceOnce sync.Once
 	traces    []string
+
+	// wrappedAt caches the rendered return trace, guarded by sitesOnce.
+	sitesOnce sync.Once
+	wrappedAt []string
+
+	// sections caches StackSections, guarded by sectionsOnce.
+	sectionsOnce sync.Once
+	sections     []StackSection
+
+	// fingerprint caches Fingerprint for the options it was computed
+	// with, so renderers that emit it hash the error once.
+	fingerprint atomic.Pointer[fingerprintCache]
 }
 
 // Error returns the combined message of the error chain.
@@ -84,48 +131,92 @@ func (e *Error) Attributes() map[string]any {
 }
 
 // Traces returns the formatted stack trace, or nil when none was captured.
-// The rendering is computed on first use and cached for the life of the
-// error; callers must treat the returned slice as read-only.
+// When the stack was deeper than the capture depth the last entry is a
+// "... N more frames" marker rather than a frame (see
+// [Error.TruncatedFrames]). For an error decoded with [ParseJSON] it
+// returns the trace rendered by the originating process. The rendering is
+// computed on first use and cached for the life of the error; callers must
+// treat the returned slice as read-only.
 func (e *Error) Traces() []string {
-	if e == nil || len(e.pcs) == 0 {
+	if e == nil {
 		return nil
 	}
+	if len(e.pcs) == 0 {
+		return e.remote
+	}
 	e.traceOnce.Do(func() {
-		e.traces = renderTraces(e.pcs)
+		e.traces = renderTraces(e.pcs, e.truncated)
 	})
 	return e.traces
 }
 
 // LogValue implements slog.LogValuer, producing a group with the keys
-// message, code, attributes, and stacktrace (each emitted only when set).
+// message, code, attributes, and stacktrace (each emitted only when set),
+// plus fingerprint when enabled with FingerprintOptions.Emit, layer_stacks
+// when outer layers captured their own stacks (see StackPerLayer), and
+// wrapped_at when a return trace was recorded (see SetReturnTrace), and
+// diagnostics last when a snapshot was attached (see Builder.Diagnostics).
+// Under SetRawStacks a stack_raw group takes the place of stacktrace.
+// [SetSchema] renames the keys.
 func (e *Error) LogValue() slog.Value {
 	if e == nil {
 		return slog.Value{}
 	}
+	s := currentSchema()
 	out := make([]slog.Attr, 0, 4)
 	if e.msg != "" {
-		out = append(out, slog.String("message", e.msg))
+		out = append(out, slog.String(s.Message, e.msg))
 	}
 	if e.code != "" {
-		out = append(out, slog.String("code", e.code))
+		out = append(out, slog.String(s.Code, e.code))
+	}
+	if EmitsFingerprint() {
+		out = append(out, slog.String(s.Fingerprint, e.Fingerprint()))
 	}
 	if len(e.attrs) > 0 {
 		sub := make([]slog.Attr, len(e.attrs))
 		for i, a := range e.attrs {
 			sub[i] = slog.Any(a.key, a.val)
 		}
-		out = append(out, slog.Attr{Key: "attributes", Value: slog.GroupValue(sub...)})
+		out = append(out, slog.Attr{Key: s.Attributes, Value: slog.GroupValue(sub...)})
+	}
+	if raw, ok := e.rawStack(); ok {
+		rs := []slog.Attr{
+			slog.String("anchor", hexAddr(raw.Anchor)),
+			slog.Any("pcs", raw.HexPCs()),
+		}
+		if raw.BuildID != "" {
+			rs = append([]slog.Attr{slog.String("build_id", raw.BuildID)}, rs...)
+		}
+		if raw.Truncated > 0 {
+			rs = append(rs, slog.Int("truncated", raw.Truncated))
+		}
+		out = append(out, slog.Attr{Key: "stack_raw", Value: slog.GroupValue(rs...)})
+	} else if traces := e.Traces(); len(traces) > 0 {
+		if s.StackString {
+			out = append(out, slog.String(s.Stacktrace, strings.Join(traces, "\n")))
+		} else {
+			out = append(out, slog.Any(s.Stacktrace, traces))
+		}
+	}
+	if secs := e.layerSections(); len(secs) > 0 {
+		out = append(out, slog.Any(s.LayerStacks, sectionsJSON(secs)))
 	}
-	if traces := e.Traces(); len(traces) > 0 {
-		out = append(out, slog.Any("stacktrace", traces))
+	if sites := e.WrappedAt(); len(sites) > 0 {
+		out = append(out, slog.Any(s.WrappedAt, sites))
+	}
+	if e.diag != nil {
+		out = append(out, slog.Attr{Key: s.Diagnostics, Value: slog.GroupValue(e.diag.logAttrs()...)})
 	}
 	return slog.GroupValue(out...)
 }
 
 // HasCode reports whether any *Error in err's chain carries the given
-// code, walking both Unwrap() error and Unwrap() []error links. Unlike
-// AsAerr followed by Code, it sees codes that outer errors did not
-// inherit: every aerr layer of the chain is checked individually.
+// code, walking Unwrap() error and Unwrap() []error links, and Cause()
+// error for errors that predate Unwrap (older github.com/pkg/errors and
+// its kin). Unlike AsAerr followed by Code, it sees codes that outer
+// errors did not inherit: every aerr layer of the chain is checked
+// individually, and so is every error implementing [Coder].
 //
 // The empty string never matches: a code of "" is treated as unset, so
 // HasCode(err, "") is always false even when the chain contains *Error
@@ -138,6 +229,9 @@ func HasCode(err error, code string) bool {
 		if e, ok := err.(*Error); ok && e != nil && e.code == code {
 			return true
 		}
+		if codeOf(err) == code {
+			return true
+		}
 		switch x := err.(type) {
 		case interface{ Unwrap() error }:
 			err = x.Unwrap()
@@ -148,6 +242,36 @@ func HasCode(err error, code string) bool {
 				}
 			}
 			return false
+		case interface{ Cause() error }:
+			err = x.Cause()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// walkChain calls fn for each error in err's chain, outermost first,
+// following Unwrap() error and Unwrap() []error links (errors.Join trees
+// depth-first) and Cause() error links, and stops as soon as fn returns
+// true. It reports whether fn did.
+func walkChain(err error, fn func(error) bool) bool {
+	for err != nil {
+		if fn(err) {
+			return true
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, sub := range x.Unwrap() {
+				if walkChain(sub, fn) {
+					return true
+				}
+			}
+			return false
+		case interface{ Cause() error }:
+			err = x.Cause()
 		default:
 			return false
 		}
@@ -155,10 +279,11 @@ func HasCode(err error, code string) bool {
 	return false
 }
 
-// AsAerr extracts an *Error from anywhere in err's chain, walking both
-// Unwrap() error and Unwrap() []error links without reflection. The first
-// return value is non-nil when the second is true; a typed-nil *Error in
-// the chain does not count as a match.
+// AsAerr extracts an *Error from anywhere in err's chain, walking
+// Unwrap() error, Unwrap() []error, and Cause() error links as HasCode
+// does, without reflection. The first return value is non-nil when the
+// second is true; a typed-nil *Error in the chain does not count as a
+// match.
 func AsAerr(err error) (*Error, bool) {
 	for err != nil {
 		if e, ok := err.(*Error); ok && e != nil {
@@ -174,6 +299,8 @@ func AsAerr(err error) (*Error, bool) {
 				}
 			}
 			return nil, false
+		case interface{ Cause() error }:
+			err = x.Cause()
 		default:
 			return nil, false
 		}
@@ -1,8 +1,10 @@
 package aerr
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 )
 
 // Builder fluently configures an *Error. Each setter mutates the receiver
@@ -15,6 +17,13 @@ type Builder struct {
 	msg          string
 	attrs        []attr
 	captureStack bool
+	merge        MergePolicy
+	ctx          context.Context
+	retryable    bool
+	retryAfter   time.Duration
+	depth        int
+	skip         int
+	diagnostics  diagLevel
 }
 
 // attr is an ordered key/value pair. Using a slice instead of a map keeps
@@ -23,6 +32,11 @@ type Builder struct {
 type attr struct {
 	key string
 	val any
+	// collected marks a []any built by MergeCollect, which later
+	// conflicts extend rather than nest.
+	collected bool
+	// fromCtx marks an attribute taken from a context (see Builder.Ctx).
+	fromCtx bool
 }
 
 // Code begins a new chain with the given error code.
@@ -46,14 +60,31 @@ func Messagef(format string, args ...any) *Builder {
 }
 
 // ErrMsg is a shortcut for Message(msg).Err(nil) that skips Builder
-// allocation entirely.
+// allocation entirely. It captures a stack trace only when the
+// [CapturePolicy] says so.
 func ErrMsg(msg string) error {
-	return &Error{msg: msg}
+	if shortcutsFinalize() {
+		b := Builder{msg: msg}
+		return b.finalize(nil, finalizeSkip)
+	}
+	return &Error{msg: msg, own: layerState{msg: msg}}
 }
 
-// Errorf is a printf-style shortcut for Messagef(...).Err(nil).
+// Errorf is a printf-style shortcut for Messagef(...).Err(nil). Like
+// ErrMsg, it captures a stack trace only when the [CapturePolicy] says so.
 func Errorf(format string, args ...any) error {
-	return &Error{msg: fmt.Sprintf(format, args...)}
+	msg := fmt.Sprintf(format, args...)
+	if shortcutsFinalize() {
+		b := Builder{msg: msg}
+		return b.finalize(nil, finalizeSkip)
+	}
+	return &Error{msg: msg, own: layerState{msg: msg}}
+}
+
+// shortcutsFinalize reports whether ErrMsg and Errorf must take the full
+// finalize path because a capture policy or the return trace is active.
+func shortcutsFinalize() bool {
+	return capturePolicy.Load() != nil || returnTrace.Load()
 }
 
 // Wrapf wraps err with a printf-style message, following the same merge
@@ -84,7 +115,8 @@ func (b *Builder) Messagef(format string, args ...any) *Builder {
 	return b
 }
 
-// StackTrace enables stack capture on the next Err / ErrMsg / Wrap.
+// StackTrace enables stack capture on the next Err / ErrMsg / Wrap. An
+// installed [CapturePolicy] may override the request for the error's code.
 func (b *Builder) StackTrace() *Builder {
 	b.captureStack = true
 	return b
@@ -115,7 +147,8 @@ func (b *Builder) With(key string, value any) *Builder {
 // with ": " as separator. When the cause chain contains an *Error (even
 // behind non-aerr wrappers such as fmt.Errorf with %w), its code is
 // inherited when the builder has none, its attributes merge under the
-// outer-wins rule, and its stack trace is inherited.
+// builder's [MergePolicy] (outer-wins by default), and its stack trace is
+// inherited.
 func (b *Builder) Err(cause error) error {
 	return b.finalize(cause, finalizeSkip)
 }
@@ -147,44 +180,121 @@ func (b *Builder) Wrap(err error) error {
 // finalize, and the exported finalizer (Err / ErrMsg / Wrap).
 const finalizeSkip = 4
 
+// noStack is the finalize skip of internal flattening that must never
+// capture, whatever the capture policy.
+const noStack = -1
+
 // finalize builds the immutable *Error from the builder's state. The
 // builder's attribute slice is copied so the issued error owns its memory
 // and later reuse of the builder cannot mutate it. skip is forwarded to
 // captureStack and must count the frames between runtime.Callers and the
-// user call site.
+// user call site, or be noStack.
 func (b *Builder) finalize(cause error, skip int) *Error {
 	e := &Error{
 		code:  b.code,
 		msg:   b.msg,
 		cause: cause,
+		own:   layerState{code: b.code, msg: b.msg},
+
+		retryable:  b.retryable,
+		retryAfter: b.retryAfter,
 	}
 	var inner *Error
+	var foreignCode string
+	var foreignAttrs []attr
 	if cause != nil {
 		e.msg = joinMsg(e.msg, cause.Error())
 		inner, _ = AsAerr(cause)
+		foreignCode, foreignAttrs = contributions(cause)
+	}
+	if e.code == "" {
+		// Wrapped foreign errors have no layer of their own, so what they
+		// contribute counts as this layer's, like their attributes below.
+		e.code = foreignCode
+		e.own.code = foreignCode
 	}
-	extra := 0
+	var ctxAttrs []attr
+	if b.ctx != nil {
+		ctxAttrs = contextAttrs(b.ctx)
+	}
+	extra := len(ctxAttrs) + len(foreignAttrs)
 	if inner != nil {
-		extra = len(inner.attrs)
+		extra += len(inner.attrs)
 	}
 	if n := len(b.attrs); n+extra > 0 {
 		attrs := make([]attr, n, n+extra)
 		copy(attrs, b.attrs)
+		attrs = mergeAttrs(attrs, ctxAttrs)
+		for i := n; i < len(attrs); i++ {
+			attrs[i].fromCtx = true
+		}
+		attrs = mergeAttrs(attrs, foreignAttrs)
 		e.attrs = attrs
+		// Merging appends, so this layer's own attributes (the builder's,
+		// the context's, and those of foreign errors it wraps) stay the
+		// prefix and can be shared rather than copied (see the in-place
+		// policies below for the exception).
+		e.own.attrs = attrs[:len(attrs):len(attrs)]
 	}
 	if inner != nil {
 		if e.code == "" {
 			e.code = inner.code
 		}
-		e.attrs = mergeAttrs(e.attrs, inner.attrs)
+		p := b.mergePolicy()
+		if (p == MergeInnerWins || p == MergeCollect) && len(e.own.attrs) > 0 {
+			// These policies rewrite values in place; keep the builder's
+			// own values intact for Layers.
+			e.own.attrs = append([]attr(nil), e.own.attrs...)
+		}
+		e.attrs = mergeAttrsWith(e.attrs, inner.attrs, p, inner.code)
 		e.pcs = inner.pcs
+		e.truncated = inner.truncated
+		e.remote = inner.remote
+		e.sites = inner.sites
+		e.remoteSites = inner.remoteSites
+		e.layerStacks = inner.layerStacks
+		e.remoteLayers = inner.remoteLayers
+		e.diag = inner.diag
+	}
+	if b.diagnostics != diagNone && e.diag == nil {
+		e.diag = snapshot(b.diagnostics)
 	}
-	if b.captureStack && len(e.pcs) == 0 {
-		e.pcs = captureStack(skip)
+	if skip != noStack && returnTrace.Load() {
+		if pc := returnSite(skip + b.skip); pc != 0 {
+			e.sites = append(e.sites[:len(e.sites):len(e.sites)], pc)
+		}
+	}
+	switch {
+	case !e.hasStack():
+		if b.wantsStack(e.code, skip) {
+			// A stack recorded by a foreign library deeper in the chain is
+			// the true origin; adopt it rather than capture here.
+			if pcs := foreignStack(cause); len(pcs) > 0 {
+				e.pcs, e.truncated = clipStack(pcs, b.stackDepthFor())
+			} else {
+				e.pcs, e.truncated = captureStack(skip+b.skip, b.stackDepthFor())
+			}
+			e.own.captured = len(e.pcs) > 0
+		}
+	case StackMode(stackMode.Load()) == StackPerLayer && b.wantsStack(e.code, skip):
+		// The inherited stack stays the deepest; this layer's capture is
+		// kept beside it.
+		pcs, truncated := captureStack(skip+b.skip, b.stackDepthFor())
+		if len(pcs) > 0 {
+			n := len(e.layerStacks)
+			e.layerStacks = append(e.layerStacks[:n:n], layerStack{code: e.code, msg: b.msg, pcs: pcs, truncated: truncated})
+			e.own.captured = true
+		}
 	}
 	return e
 }
 
+// hasStack reports whether e carries a stack trace, captured locally or
+// decoded from another process.
+func (e *Error) hasStack() bool {
+	return len(e.pcs) > 0 || len(e.remote) > 0
+}
+
 // joinMsg returns left + ": " + right, dropping the separator when either
 // side is empty.
 func joinMsg(left, right string) string {
@@ -208,9 +318,12 @@ func mergeAttrs(dst, src []attr) []attr {
 		copy(grown, dst)
 		dst = grown
 	}
+	// Only dst's own keys shadow src, so repeated "!BADKEY" entries of
+	// src are all kept.
+	n := len(dst)
 next:
 	for _, a := range src {
-		for i := range dst {
+		for i := range dst[:n] {
 			if dst[i].key == a.key {
 				continue next
 			}
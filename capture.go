@@ -0,0 +1,156 @@
+package aerr
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// CaptureMode selects when an error captures a stack trace.
+type CaptureMode int
+
+const (
+	// CaptureOptIn captures only when the builder asked for it with
+	// StackTrace (or StackTraceDepth, or a kind defined with
+	// KindStackTrace). It is the behavior without a policy.
+	CaptureOptIn CaptureMode = iota
+	// CaptureAlways captures for every error, including ErrMsg, Errorf,
+	// and Wrapf, which have no builder to ask.
+	CaptureAlways
+	// CaptureNever never captures, even when the builder asked.
+	CaptureNever
+	// CaptureSampled captures for one error in every CaptureRule.Every,
+	// whether or not the builder asked, counting per code or per call site.
+	CaptureSampled
+)
+
+// CaptureRule is the capture decision for one code, or the default.
+type CaptureRule struct {
+	Mode CaptureMode
+	// Every is the sampling interval of CaptureSampled: the first error
+	// and then one in every Every capture. Values below 2 capture every
+	// error.
+	Every int
+	// PerSite counts samples per call site instead of per code, so a hot
+	// call site cannot starve a rare one of traces.
+	PerSite bool
+}
+
+// CapturePolicy decides centrally which errors capture a stack trace, so
+// traces can be enabled in production without paying for them on every
+// error:
+//
+//	aerr.SetCapturePolicy(&aerr.CapturePolicy{
+//		Default: aerr.CaptureRule{Mode: aerr.CaptureSampled, Every: 100},
+//		Codes: map[string]aerr.CaptureRule{
+//			"INTERNAL":   {Mode: aerr.CaptureAlways},
+//			"VALIDATION": {Mode: aerr.CaptureNever},
+//		},
+//	})
+//
+// The rule is chosen by the issued error's code, inherited from the
+// wrapped error when the outer layer sets none. A policy never overrides
+// the deepest-stack rule: a layer wrapping an error that already carries
+// a trace inherits it rather than capturing.
+type CapturePolicy struct {
+	// Default applies to codes without an entry in Codes, and to errors
+	// without a code.
+	Default CaptureRule
+	// Codes overrides Default per error code.
+	Codes map[string]CaptureRule
+}
+
+// captureState is an installed CapturePolicy with its sampling counters,
+// keyed by code (string) or call site (site).
+type captureState struct {
+	policy   CapturePolicy
+	counters sync.Map
+}
+
+// capturePolicy holds the process-global policy; nil means every builder
+// decides for itself (CaptureOptIn).
+var capturePolicy atomic.Pointer[captureState]
+
+// SetCapturePolicy installs the process-global stack capture policy.
+// SetCapturePolicy(nil) removes it, leaving capture to each builder. The
+// policy is copied, so later changes to p have no effect, and installing
+// a policy restarts its sampling counts.
+func SetCapturePolicy(p *CapturePolicy) {
+	if p == nil {
+		capturePolicy.Store(nil)
+		return
+	}
+	s := &captureState{policy: CapturePolicy{Default: p.Default}}
+	if len(p.Codes) > 0 {
+		s.policy.Codes = make(map[string]CaptureRule, len(p.Codes))
+		for code, r := range p.Codes {
+			s.policy.Codes[code] = r
+		}
+	}
+	capturePolicy.Store(s)
+}
+
+// wantsStack reports whether an error issued by b with the given code
+// captures a stack trace. skip is finalize's captureStack skip; noStack
+// disables capture.
+func (b *Builder) wantsStack(code string, skip int) bool {
+	if skip == noStack {
+		return false
+	}
+	s := capturePolicy.Load()
+	if s == nil {
+		return b.captureStack
+	}
+	r, ok := s.policy.Codes[code]
+	if !ok {
+		r = s.policy.Default
+	}
+	switch r.Mode {
+	case CaptureAlways:
+		return true
+	case CaptureNever:
+		return false
+	case CaptureSampled:
+		if r.Every < 2 {
+			return true
+		}
+		var key any = code
+		if r.PerSite {
+			key = callSite(skip + b.skip + 1)
+		}
+		return s.sample(key, r.Every)
+	}
+	return b.captureStack
+}
+
+// sample counts one error under key and reports whether it is the first
+// of its interval.
+func (s *captureState) sample(key any, every int) bool {
+	c, ok := s.counters.Load(key)
+	if !ok {
+		c, _ = s.counters.LoadOrStore(key, new(atomic.Uint64))
+	}
+	n := c.(*atomic.Uint64).Add(1)
+	return (n-1)%uint64(every) == 0
+}
+
+// site identifies a call site by source position rather than PC, since a
+// function inlined into several callers has a distinct PC at each.
+type site struct {
+	file string
+	line int
+}
+
+// callSite returns the call site skip frames up, counted as for
+// captureStack, passing over frames of functions marked with Helper.
+func callSite(skip int) site {
+	var pcs [8]uintptr
+	n := runtime.Callers(skip, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !more || !isHelper(f.Function) {
+			return site{file: f.File, line: f.Line}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package aerr_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// hasStack reports whether err's nearest *Error carries a trace.
+func hasStack(err error) bool {
+	e, ok := aerr.AsAerr(err)
+	return ok && len(e.Traces()) > 0
+}
+
+func TestCapturePolicyPerCode(t *testing.T) {
+	aerr.SetCapturePolicy(&aerr.CapturePolicy{
+		Codes: map[string]aerr.CaptureRule{
+			"INTERNAL":   {Mode: aerr.CaptureAlways},
+			"VALIDATION": {Mode: aerr.CaptureNever},
+		},
+	})
+	defer aerr.SetCapturePolicy(nil)
+
+	if !hasStack(aerr.Code("INTERNAL").Err(nil)) {
+		t.Error("CaptureAlways code did not capture")
+	}
+	if hasStack(aerr.Code("VALIDATION").StackTrace().Err(nil)) {
+		t.Error("CaptureNever code captured despite the policy")
+	}
+	// The default rule is opt-in: the builder decides.
+	if hasStack(aerr.Code("OTHER").Err(nil)) {
+		t.Error("opt-in default captured without StackTrace()")
+	}
+	if !hasStack(aerr.Code("OTHER").StackTrace().Err(nil)) {
+		t.Error("opt-in default ignored StackTrace()")
+	}
+	// An inherited code selects the rule too.
+	inner := aerr.Code("VALIDATION").Message("bad input").Err(nil)
+	if hasStack(aerr.Message("outer").StackTrace().Wrap(inner)) {
+		t.Error("layer inheriting VALIDATION captured despite CaptureNever")
+	}
+	if hasStack(aerr.Message("outer").Wrap(errors.New("io"))) {
+		t.Error("code-less layer captured under the opt-in default")
+	}
+	if !hasStack(aerr.Code("INTERNAL").Wrap(errors.New("io"))) {
+		t.Error("INTERNAL layer wrapping a foreign error did not capture")
+	}
+}
+
+func TestCapturePolicyShortcuts(t *testing.T) {
+	aerr.SetCapturePolicy(&aerr.CapturePolicy{Default: aerr.CaptureRule{Mode: aerr.CaptureAlways}})
+	defer aerr.SetCapturePolicy(nil)
+
+	for name, err := range map[string]error{
+		"ErrMsg": aerr.ErrMsg("m"),
+		"Errorf": aerr.Errorf("m %d", 1),
+		"Wrapf":  aerr.Wrapf(errors.New("io"), "m %d", 1),
+	} {
+		e, _ := aerr.AsAerr(err)
+		traces := e.Traces()
+		if len(traces) == 0 {
+			t.Errorf("%s: CaptureAlways default did not capture", name)
+			continue
+		}
+		if !strings.Contains(traces[0], "capture_test.go") {
+			t.Errorf("%s: trace starts at %q, want the call site", name, traces[0])
+		}
+	}
+
+	// The shortcuts keep their fast path without a policy.
+	aerr.SetCapturePolicy(nil)
+	if hasStack(aerr.ErrMsg("m")) || hasStack(aerr.Errorf("m")) {
+		t.Error("shortcuts captured without a policy")
+	}
+}
+
+func TestCapturePolicySampled(t *testing.T) {
+	aerr.SetCapturePolicy(&aerr.CapturePolicy{
+		Default: aerr.CaptureRule{Mode: aerr.CaptureSampled, Every: 3},
+	})
+	defer aerr.SetCapturePolicy(nil)
+
+	var got []bool
+	for i := 0; i < 7; i++ {
+		got = append(got, hasStack(aerr.Code("HOT").Err(nil)))
+	}
+	want := []bool{true, false, false, true, false, false, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sampled captures = %v, want %v", got, want)
+		}
+	}
+	// Codes are counted separately: another code starts its own interval.
+	if !hasStack(aerr.Code("COLD").Err(nil)) {
+		t.Error("first error of a new code was not sampled")
+	}
+}
+
+func TestCapturePolicySampledPerSite(t *testing.T) {
+	aerr.SetCapturePolicy(&aerr.CapturePolicy{
+		Default: aerr.CaptureRule{Mode: aerr.CaptureSampled, Every: 100, PerSite: true},
+	})
+	defer aerr.SetCapturePolicy(nil)
+
+	siteA := func() error { return aerr.Code("SAME").Err(nil) }
+	siteB := func() error { return aerr.Code("SAME").Err(nil) }
+	if !hasStack(siteA()) {
+		t.Fatal("first error at site A was not sampled")
+	}
+	if hasStack(siteA()) {
+		t.Error("second error at site A was sampled with Every: 100")
+	}
+	if !hasStack(siteB()) {
+		t.Error("first error at site B shares site A's count")
+	}
+}
+
+func TestCapturePolicyKeepsDeepestStack(t *testing.T) {
+	inner := aerr.Code("INNER").StackTrace().Err(nil)
+	innerTop := func() string { e, _ := aerr.AsAerr(inner); return e.Traces()[0] }()
+
+	aerr.SetCapturePolicy(&aerr.CapturePolicy{Default: aerr.CaptureRule{Mode: aerr.CaptureAlways}})
+	defer aerr.SetCapturePolicy(nil)
+	e, _ := aerr.AsAerr(aerr.Message("outer").Wrap(inner))
+	if got := e.Traces()[0]; got != innerTop {
+		t.Errorf("outer layer recaptured: top frame %q, want inherited %q", got, innerTop)
+	}
+}
+
+func TestCapturePolicyEncodeJSON(t *testing.T) {
+	aerr.SetCapturePolicy(&aerr.CapturePolicy{Default: aerr.CaptureRule{Mode: aerr.CaptureAlways}})
+	defer aerr.SetCapturePolicy(nil)
+
+	data, _ := aerr.EncodeJSON(errors.New("plain"))
+	var wire map[string]any
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := wire["stacktrace"]; ok {
+		t.Errorf("EncodeJSON of a foreign error captured a stack: %s", data)
+	}
+}
+
+func TestSetCapturePolicyCopies(t *testing.T) {
+	p := &aerr.CapturePolicy{Codes: map[string]aerr.CaptureRule{"X": {Mode: aerr.CaptureAlways}}}
+	aerr.SetCapturePolicy(p)
+	defer aerr.SetCapturePolicy(nil)
+	p.Codes["X"] = aerr.CaptureRule{Mode: aerr.CaptureNever}
+
+	if !hasStack(aerr.Code("X").Err(nil)) {
+		t.Error("mutating the policy after SetCapturePolicy changed it")
+	}
+}
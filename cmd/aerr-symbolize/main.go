@@ -0,0 +1,206 @@
+// Command aerr-symbolize turns the "stack_raw" objects that aerr writes
+// under aerr.SetRawStacks back into the usual "stacktrace" lines, given
+// the binary that logged them:
+//
+//	aerr-symbolize -binary ./server < app.log
+//	aerr-symbolize -binary ./server app.log.1 app.log.2
+//
+// Input is JSON Lines, as written by the slog, zap, and zerolog
+// integrations. Every "stack_raw" object, at any depth, is replaced in
+// place by a "stacktrace" list of "file:line (function)" entries; other
+// keys keep their order. Lines that are not JSON pass through unchanged.
+// The replacement is always a list under "stacktrace", whatever key and
+// form the logging process's aerr.SetSchema chose for rendered traces.
+//
+// The binary must be the exact build that logged the stacks. Stacks whose
+// build ID differs are left raw and reported on stderr unless -force is
+// set. Stripping symbols (-ldflags=-s -w) keeps the Go line table, so a
+// stripped binary works too, but a -trimpath build yields trimmed paths.
+// Calls that were inlined report the enclosing function's name next to
+// the inlined code's file and line.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	binary := flag.String("binary", "", "path to the binary that logged the stacks (required)")
+	force := flag.Bool("force", false, "symbolize stacks whose build ID does not match the binary")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: aerr-symbolize -binary path [-force] [file ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *binary == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	sym, err := openBinary(*binary)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "aerr-symbolize:", err)
+		os.Exit(1)
+	}
+	sym.force = *force
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	failed := false
+	process := func(name string, r io.Reader) {
+		if err := rewriteLines(out, r, sym); err != nil {
+			fmt.Fprintf(os.Stderr, "aerr-symbolize: %s: %v\n", name, err)
+			failed = true
+		}
+	}
+	if flag.NArg() == 0 {
+		process("stdin", os.Stdin)
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "aerr-symbolize:", err)
+			failed = true
+			continue
+		}
+		process(name, f)
+		f.Close()
+	}
+	if failed {
+		out.Flush()
+		os.Exit(1)
+	}
+}
+
+// rewriteLines copies r to w line by line, rewriting the "stack_raw"
+// objects of every JSON line. Errors from individual stacks are reported
+// after the whole input was copied; those stacks are kept raw.
+func rewriteLines(w io.Writer, r io.Reader, sym *symbolizer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	var errs []error
+	var buf bytes.Buffer
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Bytes()
+		buf.Reset()
+		rw := rewriter{sym: sym, out: &buf}
+		if !json.Valid(line) || rw.rewrite(line) != nil {
+			buf.Reset()
+			buf.Write(line)
+		}
+		for _, err := range rw.errs {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// rewriter re-encodes one JSON value token by token, so object keys keep
+// their order, replacing "stack_raw" members.
+type rewriter struct {
+	sym  *symbolizer
+	dec  *json.Decoder
+	out  *bytes.Buffer
+	errs []error
+}
+
+func (rw *rewriter) rewrite(doc []byte) error {
+	rw.dec = json.NewDecoder(bytes.NewReader(doc))
+	rw.dec.UseNumber()
+	return rw.value()
+}
+
+// value copies the next value from the decoder.
+func (rw *rewriter) value() error {
+	tok, err := rw.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		rw.out.WriteByte('{')
+		for i := 0; rw.dec.More(); i++ {
+			if i > 0 {
+				rw.out.WriteByte(',')
+			}
+			key, err := rw.dec.Token()
+			if err != nil {
+				return err
+			}
+			if key == "stack_raw" {
+				if err := rw.stackRaw(); err != nil {
+					return err
+				}
+				continue
+			}
+			rw.scalar(key)
+			rw.out.WriteByte(':')
+			if err := rw.value(); err != nil {
+				return err
+			}
+		}
+		rw.out.WriteByte('}')
+		_, err = rw.dec.Token()
+		return err
+	case json.Delim('['):
+		rw.out.WriteByte('[')
+		for i := 0; rw.dec.More(); i++ {
+			if i > 0 {
+				rw.out.WriteByte(',')
+			}
+			if err := rw.value(); err != nil {
+				return err
+			}
+		}
+		rw.out.WriteByte(']')
+		_, err = rw.dec.Token()
+		return err
+	}
+	rw.scalar(tok)
+	return nil
+}
+
+// stackRaw replaces the "stack_raw" member whose key was just read with
+// "stacktrace", or copies it unchanged when it cannot be symbolized.
+func (rw *rewriter) stackRaw() error {
+	var msg json.RawMessage
+	if err := rw.dec.Decode(&msg); err != nil {
+		return err
+	}
+	var raw rawStack
+	err := json.Unmarshal(msg, &raw)
+	var traces []string
+	if err == nil {
+		traces, err = rw.sym.symbolize(raw)
+	}
+	if err != nil {
+		rw.errs = append(rw.errs, err)
+		rw.out.WriteString(`"stack_raw":`)
+		rw.out.Write(msg)
+		return nil
+	}
+	rw.out.WriteString(`"stacktrace":`)
+	rw.scalar(traces)
+	return nil
+}
+
+// scalar writes v as JSON without escaping HTML characters.
+func (rw *rewriter) scalar(v any) {
+	enc := json.NewEncoder(rw.out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	rw.out.Truncate(rw.out.Len() - 1) // Encode appends a newline
+}
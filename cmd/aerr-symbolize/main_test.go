@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// selfSymbolizer opens the running test binary.
+func selfSymbolizer(t *testing.T) *symbolizer {
+	t.Helper()
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("symbolization supports ELF and Mach-O binaries")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sym, err := openBinary(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sym
+}
+
+func rawError(t *testing.T) (*aerr.Error, []byte) {
+	t.Helper()
+	aerr.SetRawStacks(true)
+	t.Cleanup(func() { aerr.SetRawStacks(false) })
+	e, _ := aerr.AsAerr(aerr.Code("DB_DOWN").Message("query failed").StackTrace().Err(nil))
+	line, err := json.Marshal(map[string]any{"level": "error", "error": e, "msg": "<request>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, line
+}
+
+func TestRewriteMatchesTraces(t *testing.T) {
+	sym := selfSymbolizer(t)
+	e, line := rawError(t)
+	if !bytes.Contains(line, []byte(`"stack_raw":{"build_id":`)) {
+		t.Fatalf("no stack_raw with build_id in %s", line)
+	}
+	var out bytes.Buffer
+	if err := rewriteLines(&out, bytes.NewReader(line), sym); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Error struct {
+			Code       string   `json:"code"`
+			Stacktrace []string `json:"stacktrace"`
+		} `json:"error"`
+		Msg string `json:"msg"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%v in %s", err, out.Bytes())
+	}
+	if got.Error.Code != "DB_DOWN" || got.Msg != "<request>" {
+		t.Fatalf("other keys not preserved: %s", out.Bytes())
+	}
+	want := e.Traces()
+	if len(got.Error.Stacktrace) == 0 || got.Error.Stacktrace[0] != want[0] {
+		t.Fatalf("stacktrace = %q, want first frame %q", got.Error.Stacktrace, want[0])
+	}
+	if !strings.Contains(want[0], "rawError") {
+		t.Fatalf("first frame %q is not the capturing function", want[0])
+	}
+}
+
+func TestRewriteKeepsKeyOrder(t *testing.T) {
+	sym := selfSymbolizer(t)
+	_, line := rawError(t)
+	var out bytes.Buffer
+	if err := rewriteLines(&out, bytes.NewReader(line), sym); err != nil {
+		t.Fatal(err)
+	}
+	s := out.String()
+	if !strings.HasPrefix(s, `{"error":{"code":"DB_DOWN","message":"query failed","stacktrace":[`) ||
+		!strings.HasSuffix(s, `,"level":"error","msg":"<request>"}`+"\n") {
+		t.Fatalf("rewritten line = %s", s)
+	}
+}
+
+func TestRewriteBuildIDMismatch(t *testing.T) {
+	sym := selfSymbolizer(t)
+	line := []byte(`{"stack_raw":{"build_id":"other","anchor":"0x1","pcs":["0x2"]}}`)
+	var out bytes.Buffer
+	err := rewriteLines(&out, bytes.NewReader(line), sym)
+	if !errors.Is(err, errBuildID) {
+		t.Fatalf("err = %v, want build ID mismatch", err)
+	}
+	if got := strings.TrimSuffix(out.String(), "\n"); got != string(line) {
+		t.Fatalf("mismatched stack rewritten to %s", got)
+	}
+}
+
+func TestRewritePassesThroughText(t *testing.T) {
+	sym := selfSymbolizer(t)
+	in := "starting server\n{\"msg\":\"ok\"}\n"
+	var out bytes.Buffer
+	if err := rewriteLines(&out, strings.NewReader(in), sym); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != in {
+		t.Fatalf("output = %q, want %q", out.String(), in)
+	}
+}
+
+func TestSymbolizeTruncationMarker(t *testing.T) {
+	sym := selfSymbolizer(t)
+	got, err := sym.symbolize(rawStack{Anchor: fmt.Sprintf("%#x", sym.anchor), Truncated: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "... 3 more frames" {
+		t.Fatalf("symbolize = %q", got)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/gosym"
+	"debug/macho"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tafaquh/aerr"
+	"github.com/tafaquh/aerr/internal/buildid"
+	"github.com/tafaquh/aerr/internal/funcname"
+)
+
+// rawStack is the decoded "stack_raw" object.
+type rawStack struct {
+	BuildID   string   `json:"build_id"`
+	Anchor    string   `json:"anchor"`
+	PCs       []string `json:"pcs"`
+	Truncated int      `json:"truncated"`
+}
+
+// symbolizer resolves PCs against one binary's line table.
+type symbolizer struct {
+	table    *gosym.Table
+	anchor   uint64 // static entry address of aerr.AnchorFunc
+	buildID  string
+	revision string
+	force    bool // symbolize stacks whose build ID does not match
+}
+
+// openBinary loads the line table of the ELF or Mach-O binary at path.
+func openBinary(path string) (*symbolizer, error) {
+	pclntab, text, err := readPclntab(path)
+	if err != nil {
+		return nil, err
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(pclntab, text))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	fn := table.LookupFunc(aerr.AnchorFunc)
+	if fn == nil {
+		return nil, fmt.Errorf("%s: %s not found; the binary does not link aerr", path, aerr.AnchorFunc)
+	}
+	return &symbolizer{
+		table:    table,
+		anchor:   fn.Entry,
+		buildID:  buildid.Read(path),
+		revision: buildid.Revision(path),
+	}, nil
+}
+
+// readPclntab returns the Go line table section and the text start
+// address it is relative to.
+func readPclntab(path string) (pclntab []byte, text uint64, err error) {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		tab, txt := f.Section(".gopclntab"), f.Section(".text")
+		if tab == nil || txt == nil {
+			return nil, 0, fmt.Errorf("%s: no Go line table", path)
+		}
+		data, err := tab.Data()
+		return data, txt.Addr, err
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		tab, txt := f.Section("__gopclntab"), f.Section("__text")
+		if tab == nil || txt == nil {
+			return nil, 0, fmt.Errorf("%s: no Go line table", path)
+		}
+		data, err := tab.Data()
+		return data, txt.Addr, err
+	}
+	return nil, 0, fmt.Errorf("%s: not an ELF or Mach-O binary", path)
+}
+
+// errBuildID reports a stack captured by a different binary.
+var errBuildID = errors.New("build ID mismatch")
+
+// symbolize renders raw as "file:line (function)" lines, dropping aerr's
+// own frames and the standard library's like the default frame policy,
+// and ending with a "... N more frames" marker when the capture was
+// truncated.
+func (s *symbolizer) symbolize(raw rawStack) ([]string, error) {
+	if !s.force && !s.matches(raw.BuildID) {
+		return nil, fmt.Errorf("%w: stack from %q, binary is %q", errBuildID, raw.BuildID, s.buildID)
+	}
+	anchor, err := parseAddr(raw.Anchor)
+	if err != nil {
+		return nil, fmt.Errorf("anchor: %w", err)
+	}
+	slide := anchor - s.anchor
+	out := make([]string, 0, len(raw.PCs)+1)
+	for _, h := range raw.PCs {
+		pc, err := parseAddr(h)
+		if err != nil {
+			return nil, fmt.Errorf("pc: %w", err)
+		}
+		// PCs are return addresses; look up the call instruction.
+		file, line, fn := s.table.PCToLine(pc - slide - 1)
+		if fn == nil {
+			out = append(out, h+" (?)")
+			continue
+		}
+		if funcname.Hidden(fn.Name) {
+			continue
+		}
+		out = append(out, file+":"+strconv.Itoa(line)+" ("+fn.Name+")")
+	}
+	if raw.Truncated == 1 {
+		out = append(out, "... 1 more frame")
+	} else if raw.Truncated > 1 {
+		out = append(out, "... "+strconv.Itoa(raw.Truncated)+" more frames")
+	}
+	return out, nil
+}
+
+// matches reports whether a recorded build ID identifies this binary. An
+// empty one matches anything, since it carries no information.
+func (s *symbolizer) matches(id string) bool {
+	switch {
+	case id == "":
+		return true
+	case strings.HasPrefix(id, buildid.RevisionPrefix):
+		return id == s.revision
+	default:
+		return id == s.buildID
+	}
+}
+
+// parseAddr parses a "0x"-prefixed hexadecimal address.
+func parseAddr(s string) (uint64, error) {
+	hex, ok := strings.CutPrefix(s, "0x")
+	if !ok {
+		return 0, fmt.Errorf("%q is not a hex address", s)
+	}
+	return strconv.ParseUint(hex, 16, 64)
+}
@@ -0,0 +1,135 @@
+package aerr
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ctxAttrsKey is the context key under which WithContextAttrs stores its
+// attributes.
+type ctxAttrsKey struct{}
+
+// badKey is the key given to a value without a string key, matching the
+// log/slog convention.
+const badKey = "!BADKEY"
+
+// WithContextAttrs returns a copy of ctx carrying the given attributes, so
+// builders finalized with [Builder.Ctx] (or [Builder.ErrCtx] /
+// [Builder.WrapCtx]) attach them without repeating them at every call
+// site. args alternate string keys and values as in log/slog; each value
+// with no string key is appended under "!BADKEY". Attributes already in
+// ctx are kept, and a repeated string key overwrites its earlier value.
+// Values are subject to [RedactKeys] at the time of the call.
+//
+//	ctx = aerr.WithContextAttrs(ctx, "request_id", reqID, "tenant_id", tenant)
+func WithContextAttrs(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(ctxAttrsKey{}).([]attr)
+	attrs := make([]attr, len(prev), len(prev)+len(args)/2+1)
+	copy(attrs, prev)
+	for len(args) > 0 {
+		key, ok := args[0].(string)
+		if !ok || len(args) == 1 {
+			// Like slog, keep every unkeyed value rather than letting
+			// each overwrite the last.
+			attrs = append(attrs, attr{key: badKey, val: args[0]})
+			args = args[1:]
+			continue
+		}
+		attrs = setAttr(attrs, key, redactValue(key, args[1]))
+		args = args[2:]
+	}
+	return context.WithValue(ctx, ctxAttrsKey{}, attrs)
+}
+
+// setAttr overwrites key in place when present and appends it otherwise,
+// the same rule as Builder.With.
+func setAttr(attrs []attr, key string, value any) []attr {
+	for i := range attrs {
+		if attrs[i].key == key {
+			attrs[i].val = value
+			return attrs
+		}
+	}
+	return append(attrs, attr{key: key, val: value})
+}
+
+// TraceExtractor reports the trace and span IDs of the span active in
+// ctx, returning empty strings when there is none. It lets errors pick up
+// OpenTelemetry (or any other tracer's) IDs without the core module
+// importing a tracing SDK:
+//
+//	aerr.SetTraceExtractor(func(ctx context.Context) (string, string) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		if !sc.IsValid() {
+//			return "", ""
+//		}
+//		return sc.TraceID().String(), sc.SpanID().String()
+//	})
+type TraceExtractor func(ctx context.Context) (traceID, spanID string)
+
+// traceExtractor holds the process-global TraceExtractor; nil disables it.
+var traceExtractor atomic.Pointer[TraceExtractor]
+
+// SetTraceExtractor installs the process-global extractor consulted when a
+// builder finalizes with a context. Non-empty IDs are attached as the
+// "trace_id" and "span_id" attributes, after the context's own
+// attributes. SetTraceExtractor(nil) removes it.
+func SetTraceExtractor(fn TraceExtractor) {
+	if fn == nil {
+		traceExtractor.Store(nil)
+		return
+	}
+	traceExtractor.Store(&fn)
+}
+
+// Ctx sets the context whose attributes (see [WithContextAttrs]) and
+// trace IDs (see [SetTraceExtractor]) are merged into the issued error.
+// They rank below the builder's own attributes and above the wrapped
+// error's under the outer-wins rule.
+func (b *Builder) Ctx(ctx context.Context) *Builder {
+	b.ctx = ctx
+	return b
+}
+
+// ErrCtx is Err with ctx's attributes merged in, as if set with Ctx. The
+// builder itself is left unchanged.
+func (b *Builder) ErrCtx(ctx context.Context, cause error) error {
+	c := *b
+	c.ctx = ctx
+	return c.finalize(cause, finalizeSkip)
+}
+
+// WrapCtx is Wrap with ctx's attributes merged in, as if set with Ctx. The
+// builder itself is left unchanged. Returns nil when err is nil.
+func (b *Builder) WrapCtx(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	c := *b
+	c.ctx = ctx
+	return c.finalize(err, finalizeSkip)
+}
+
+// contextAttrs returns the attributes ctx contributes: those stored by
+// WithContextAttrs followed by the extracted trace IDs. The result may
+// alias the context's slice and must not be modified.
+func contextAttrs(ctx context.Context) []attr {
+	attrs, _ := ctx.Value(ctxAttrsKey{}).([]attr)
+	fn := traceExtractor.Load()
+	if fn == nil {
+		return attrs
+	}
+	traceID, spanID := (*fn)(ctx)
+	if traceID == "" && spanID == "" {
+		return attrs
+	}
+	out := make([]attr, len(attrs), len(attrs)+2)
+	copy(out, attrs)
+	if traceID != "" && !hasAttr(out, "trace_id") {
+		out = append(out, attr{key: "trace_id", val: traceID})
+	}
+	if spanID != "" && !hasAttr(out, "span_id") {
+		out = append(out, attr{key: "span_id", val: spanID})
+	}
+	return out
+}
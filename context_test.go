@@ -0,0 +1,89 @@
+package aerr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+func TestContextAttrsMerged(t *testing.T) {
+	ctx := aerr.WithContextAttrs(context.Background(), "request_id", "r-1", "tenant_id", "t-1")
+	ctx = aerr.WithContextAttrs(ctx, "tenant_id", "t-2")
+
+	inner := aerr.Code("DB").With("request_id", "inner").With("table", "users").Err(nil)
+	err := aerr.Code("SVC").With("tenant_id", "explicit").Ctx(ctx).Wrap(inner)
+
+	keys, vals := rangeAttrs(t, err)
+	wantKeys := []string{"tenant_id", "request_id", "table"}
+	wantVals := []any{"explicit", "r-1", "users"}
+	if !reflect.DeepEqual(keys, wantKeys) || !reflect.DeepEqual(vals, wantVals) {
+		t.Errorf("attrs = %v=%v, want %v=%v (builder > context > inner)", keys, vals, wantKeys, wantVals)
+	}
+}
+
+func TestErrCtxAndWrapCtx(t *testing.T) {
+	ctx := aerr.WithContextAttrs(context.Background(), "request_id", "r-9")
+	b := aerr.Code("TMPL")
+
+	keys, _ := rangeAttrs(t, b.ErrCtx(ctx, nil))
+	if !reflect.DeepEqual(keys, []string{"request_id"}) {
+		t.Errorf("ErrCtx keys = %v, want [request_id]", keys)
+	}
+	keys, _ = rangeAttrs(t, b.WrapCtx(ctx, aerr.ErrMsg("cause")))
+	if !reflect.DeepEqual(keys, []string{"request_id"}) {
+		t.Errorf("WrapCtx keys = %v, want [request_id]", keys)
+	}
+	if b.WrapCtx(ctx, nil) != nil {
+		t.Error("WrapCtx(ctx, nil) != nil")
+	}
+
+	// The template builder is not bound to the context.
+	e, _ := aerr.AsAerr(b.Err(nil))
+	if e.NumAttrs() != 0 {
+		t.Errorf("builder kept context attributes after ErrCtx: %v", e.Attributes())
+	}
+}
+
+func TestContextAttrsBadKeyAndRedaction(t *testing.T) {
+	aerr.RedactKeys("token")
+	defer aerr.RedactKeys()
+
+	ctx := aerr.WithContextAttrs(context.Background(), "token", "secret", 42, "dangling")
+	keys, vals := rangeAttrs(t, aerr.Code("X").Ctx(ctx).Err(nil))
+	// Like slog, every unkeyed value is kept.
+	if !reflect.DeepEqual(keys, []string{"token", "!BADKEY", "!BADKEY"}) || vals[1] != 42 || vals[2] != "dangling" {
+		t.Fatalf("attrs = %v %v, want [token !BADKEY=42 !BADKEY=dangling]", keys, vals)
+	}
+	if _, ok := vals[0].(aerr.Redacted); !ok {
+		t.Errorf("token = %#v, want aerr.Redacted", vals[0])
+	}
+}
+
+func TestTraceExtractor(t *testing.T) {
+	type spanKey struct{}
+	aerr.SetTraceExtractor(func(ctx context.Context) (string, string) {
+		if span, ok := ctx.Value(spanKey{}).(string); ok {
+			return "trace-" + span, span
+		}
+		return "", ""
+	})
+	defer aerr.SetTraceExtractor(nil)
+
+	ctx := context.WithValue(context.Background(), spanKey{}, "s1")
+	ctx = aerr.WithContextAttrs(ctx, "request_id", "r-1")
+	keys, vals := rangeAttrs(t, aerr.Code("X").Ctx(ctx).Err(nil))
+	if !reflect.DeepEqual(keys, []string{"request_id", "trace_id", "span_id"}) {
+		t.Fatalf("keys = %v, want request_id, trace_id, span_id", keys)
+	}
+	if vals[1] != "trace-s1" || vals[2] != "s1" {
+		t.Errorf("trace/span = %v/%v, want trace-s1/s1", vals[1], vals[2])
+	}
+
+	// No active span: nothing is added.
+	e, _ := aerr.AsAerr(aerr.Code("X").Ctx(context.Background()).Err(nil))
+	if e.NumAttrs() != 0 {
+		t.Errorf("attrs without span = %v, want none", e.Attributes())
+	}
+}
@@ -0,0 +1,98 @@
+package aerr
+
+import "log/slog"
+
+// Coder is implemented by non-aerr error types that carry an error code,
+// such as a database driver's error or a domain ValidationError. When an
+// aerr layer wraps one without setting a code of its own, the layer takes
+// the Coder's code; HasCode matches it as well.
+//
+//	func (e *ValidationError) ErrorCode() string { return "VALIDATION" }
+type Coder interface {
+	ErrorCode() string
+}
+
+// Attributer is implemented by non-aerr error types that carry structured
+// fields. When an aerr layer wraps one, its attributes join the layer's
+// after the builder's own and the context's, so a key set on the builder
+// wins. Group attributes become nested maps, and values are subject to
+// [RedactKeys].
+//
+//	func (e *ValidationError) ErrorAttrs() []slog.Attr {
+//		return []slog.Attr{slog.String("field", e.Field)}
+//	}
+type Attributer interface {
+	ErrorAttrs() []slog.Attr
+}
+
+// From returns the *Error that renderers show for err. It is err itself
+// when err is an *Error, and AsAerr's result when no Coder or Attributer
+// sits above the first *Error of the chain. Otherwise From flattens err
+// the way wrapping would, so the contributed code and attributes are
+// kept: the result has err's full message and err as its cause, and
+// inherits the stack of an *Error deeper in the chain. ok is false when
+// err neither carries an *Error nor contributes anything.
+//
+// The logging adapters render through From, so a foreign error logged
+// without an aerr wrapper still shows its code and attributes.
+func From(err error) (*Error, bool) {
+	if e, ok := err.(*Error); ok {
+		return e, e != nil
+	}
+	if code, attrs := contributions(err); code == "" && len(attrs) == 0 {
+		return AsAerr(err)
+	}
+	var b Builder
+	return b.finalize(err, noStack), true
+}
+
+// contributions collects what the non-aerr errors of err's chain above
+// its first *Error supply: the outermost Coder's code and every
+// Attributer's attributes, outermost first, the first occurrence of a key
+// winning.
+func contributions(err error) (code string, attrs []attr) {
+	walkChain(err, func(err error) bool {
+		if _, ok := err.(*Error); ok {
+			return true
+		}
+		if isNilValue(err) {
+			return false
+		}
+		if c, ok := err.(Coder); ok && code == "" {
+			code = c.ErrorCode()
+		}
+		if a, ok := err.(Attributer); ok {
+			for _, sa := range a.ErrorAttrs() {
+				if sa.Key != "" && !hasAttr(attrs, sa.Key) {
+					attrs = append(attrs, attr{key: sa.Key, val: redactValue(sa.Key, slogValue(sa.Value))})
+				}
+			}
+		}
+		return false
+	})
+	return code, attrs
+}
+
+// slogValue converts a slog.Value to the plain value aerr stores: groups
+// become map[string]any and LogValuers are resolved.
+func slogValue(v slog.Value) any {
+	v = v.Resolve()
+	if v.Kind() != slog.KindGroup {
+		return v.Any()
+	}
+	group := v.Group()
+	m := make(map[string]any, len(group))
+	for _, a := range group {
+		m[a.Key] = slogValue(a.Value)
+	}
+	return m
+}
+
+// codeOf returns the code err contributes as a Coder, or "".
+func codeOf(err error) string {
+	c, ok := err.(Coder)
+	if !ok || isNilValue(err) {
+		return ""
+	}
+	return c.ErrorCode()
+}
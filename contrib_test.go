@@ -0,0 +1,130 @@
+package aerr_test
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// validationError is a domain error contributing a code and attributes.
+type validationError struct {
+	field string
+}
+
+func (e *validationError) Error() string     { return "invalid " + e.field }
+func (e *validationError) ErrorCode() string { return "VALIDATION" }
+func (e *validationError) ErrorAttrs() []slog.Attr {
+	return []slog.Attr{
+		slog.String("field", e.field),
+		slog.String("password", "hunter2"),
+		slog.Group("rule", slog.String("name", "min_len"), slog.Int("min", 8)),
+	}
+}
+
+func TestWrapAbsorbsContributions(t *testing.T) {
+	aerr.RedactKeys("password")
+	t.Cleanup(func() { aerr.RedactKeys() })
+
+	e, _ := aerr.AsAerr(aerr.Message("save user").With("field", "builder").Wrap(&validationError{field: "email"}))
+	if e.Code() != "VALIDATION" {
+		t.Errorf("Code() = %q, want the Coder's code", e.Code())
+	}
+	attrs := e.Attributes()
+	if attrs["field"] != "builder" {
+		t.Errorf("field = %v, want the builder's value to win", attrs["field"])
+	}
+	if _, ok := attrs["password"].(aerr.Redacted); !ok {
+		t.Errorf("password = %#v, want redacted", attrs["password"])
+	}
+	rule, _ := attrs["rule"].(map[string]any)
+	if rule["name"] != "min_len" || rule["min"] != int64(8) {
+		t.Errorf("rule = %#v, want a nested map", attrs["rule"])
+	}
+
+	e, _ = aerr.AsAerr(aerr.Code("OWN").Wrap(&validationError{field: "email"}))
+	if e.Code() != "OWN" {
+		t.Errorf("Code() = %q, want the builder's code to win", e.Code())
+	}
+}
+
+func TestContributionsAboveInnerAerr(t *testing.T) {
+	inner := aerr.Code("INNER").With("k", "inner").Err(nil)
+	verr := fmt.Errorf("validate: %w", &wrappingValidation{validationError{field: "name"}, inner})
+	e, _ := aerr.AsAerr(aerr.Message("outer").Wrap(verr))
+	if e.Code() != "VALIDATION" {
+		t.Errorf("Code() = %q, want the Coder above the inner aerr layer", e.Code())
+	}
+	if attrs := e.Attributes(); attrs["field"] != "name" || attrs["k"] != "inner" {
+		t.Errorf("Attributes() = %v", attrs)
+	}
+}
+
+// wrappingValidation contributes like validationError and wraps a cause.
+type wrappingValidation struct {
+	validationError
+	cause error
+}
+
+func (e *wrappingValidation) Unwrap() error { return e.cause }
+
+func TestHasCodeMatchesCoder(t *testing.T) {
+	err := fmt.Errorf("handler: %w", &validationError{field: "email"})
+	if !aerr.HasCode(err, "VALIDATION") {
+		t.Error("HasCode does not match a Coder")
+	}
+	if aerr.HasCode(err, "OTHER") {
+		t.Error("HasCode matched the wrong code")
+	}
+	var nilErr *validationError
+	if aerr.HasCode(fmt.Errorf("w: %w", nilErr), "VALIDATION") {
+		t.Error("HasCode matched a typed-nil Coder")
+	}
+}
+
+func TestFrom(t *testing.T) {
+	verr := &validationError{field: "email"}
+	e, ok := aerr.From(verr)
+	if !ok || e.Code() != "VALIDATION" || e.Error() != "invalid email" || !errors.Is(e, verr) {
+		t.Fatalf("From(foreign) = %v, %v", e, ok)
+	}
+	if e.Attributes()["field"] != "email" {
+		t.Errorf("From(foreign) attributes = %v", e.Attributes())
+	}
+
+	if _, ok := aerr.From(errors.New("plain")); ok {
+		t.Error("From(plain) ok, want false")
+	}
+	inner := aerr.Code("X").Err(nil)
+	if got, ok := aerr.From(fmt.Errorf("w: %w", inner)); !ok || got != inner {
+		t.Errorf("From(fmt wrapper) = %v, want the inner *Error", got)
+	}
+	var nilAerr *aerr.Error
+	if _, ok := aerr.From(nilAerr); ok {
+		t.Error("From(typed nil) ok, want false")
+	}
+}
+
+func TestLayerOwnsContributions(t *testing.T) {
+	err := aerr.Message("save user").Wrap(&validationError{field: "email"})
+	l := aerr.Layers(err)[0]
+	if l.Code != "VALIDATION" {
+		t.Errorf("Layer.Code = %q, want the wrapped Coder's code", l.Code)
+	}
+	if len(l.Attrs) == 0 || l.Attrs[0].Key != "field" {
+		t.Errorf("Layer.Attrs = %v, want the wrapped Attributer's", l.Attrs)
+	}
+}
+
+func TestWriteDevShowsContributions(t *testing.T) {
+	var b strings.Builder
+	if err := aerr.WriteDev(&b, fmt.Errorf("save: %w", &validationError{field: "email"})); err != nil {
+		t.Fatal(err)
+	}
+	if out := b.String(); !strings.HasPrefix(out, "[VALIDATION] save: invalid email\n") || !strings.Contains(out, "field") {
+		t.Errorf("WriteDev = %q", out)
+	}
+}
@@ -0,0 +1,119 @@
+package aerr
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDev writes err to w in a layout for reading in a terminal during
+// development, rather than for log collectors:
+//
+//	[NOT_FOUND] load user 7: no row
+//	    user_id = 7
+//
+//	    at github.com/acme/app/repo.FindUser
+//	       /app/repo/user.go:42
+//	           41 | 	if err == sql.ErrNoRows {
+//	         > 42 | 		return aerr.Code("NOT_FOUND").StackTrace().Wrap(err)
+//	           43 | 	}
+//
+// The header is err's full message, prefixed by its code. Attributes
+// follow, then one "at" entry per frame of [Error.Frames] with a source
+// snippet under [SetSourceContext], the stacks of outer layers under
+// [StackPerLayer], the return trace, and the diagnostics snapshot. Code
+// and attributes are those [From] reports, so a foreign [Coder] or
+// [Attributer] shows as well; an error that neither carries an *Error nor
+// contributes anything is written as its message alone. WriteDev returns
+// the first error from w.
+func WriteDev(w io.Writer, err error) error {
+	var b strings.Builder
+	writeDev(&b, err)
+	_, werr := io.WriteString(w, b.String())
+	return werr
+}
+
+func writeDev(b *strings.Builder, err error) {
+	msg, ok := errString(err)
+	if !ok {
+		b.WriteString("<nil>\n")
+		return
+	}
+	e, ok := From(err)
+	if ok && e.code != "" {
+		b.WriteString("[")
+		b.WriteString(e.code)
+		b.WriteString("] ")
+	}
+	b.WriteString(msg)
+	b.WriteByte('\n')
+	if !ok {
+		return
+	}
+	if len(e.attrs) > 0 {
+		width := 0
+		for _, a := range e.attrs {
+			width = max(width, len(a.key))
+		}
+		for _, a := range e.attrs {
+			fmt.Fprintf(b, "    %-*s = %v\n", width, a.key, a.val)
+		}
+	}
+	var frames, src []Frame
+	n := int(sourceContext.Load())
+	if n > 0 {
+		frames, src = e.sourceFrames()
+	} else {
+		frames = e.Frames()
+	}
+	if len(frames) > 0 {
+		b.WriteByte('\n')
+		for i, f := range frames {
+			b.WriteString("    at ")
+			if f.Function == "" {
+				// A remote trace line that did not parse.
+				b.WriteString(f.File)
+				b.WriteByte('\n')
+				continue
+			}
+			b.WriteString(f.Function)
+			b.WriteString("\n       ")
+			b.WriteString(f.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(f.Line))
+			if i < len(src) {
+				writeSnippet(b, "         ", src[i], n)
+			}
+			b.WriteByte('\n')
+		}
+		if t := e.TruncatedFrames(); t > 0 {
+			b.WriteString("    ")
+			b.WriteString(truncationMarker(t))
+			b.WriteByte('\n')
+		}
+	}
+	for _, sec := range e.layerSections() {
+		b.WriteString("\n    stack of ")
+		b.WriteString(sectionLabel(sec))
+		b.WriteString(":\n")
+		for _, fr := range sec.Traces {
+			b.WriteString("        ")
+			b.WriteString(fr)
+			b.WriteByte('\n')
+		}
+	}
+	if sites := e.WrappedAt(); len(sites) > 0 {
+		b.WriteString("\n    wrapped at:\n")
+		for _, site := range sites {
+			b.WriteString("        ")
+			b.WriteString(site)
+			b.WriteByte('\n')
+		}
+	}
+	if e.diag != nil {
+		b.WriteString("\n    diagnostics:")
+		e.diag.writeText(b, "        ")
+		b.WriteByte('\n')
+	}
+}
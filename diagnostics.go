@@ -0,0 +1,233 @@
+package aerr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Diagnostics is a snapshot of the process taken when an error was
+// finalized with [Builder.Diagnostics] or [Builder.GoroutineDump]. It is
+// rendered as a "diagnostics" object next to, not inside, the attributes.
+type Diagnostics struct {
+	// GoroutineID is the ID of the goroutine that finalized the error.
+	GoroutineID int64 `json:"goroutine_id,omitempty"`
+	// NumGoroutine is the number of live goroutines.
+	NumGoroutine int `json:"num_goroutine"`
+	// GOMAXPROCS is the GOMAXPROCS setting.
+	GOMAXPROCS int `json:"gomaxprocs"`
+	// HeapAlloc is the number of bytes in live and not yet swept heap
+	// objects, as runtime/metrics reports it.
+	HeapAlloc uint64 `json:"heap_alloc_bytes"`
+	// HeapObjects is the number of objects on the heap.
+	HeapObjects uint64 `json:"heap_objects"`
+	// HeapGoal is the heap size target for the end of the GC cycle.
+	HeapGoal uint64 `json:"heap_goal_bytes"`
+	// GCCycles is the number of completed GC cycles.
+	GCCycles uint64 `json:"gc_cycles"`
+	// GoVersion is the Go version that built the binary.
+	GoVersion string `json:"go_version,omitempty"`
+	// Module and ModuleVersion are the main module's path and version
+	// ("(devel)" for local builds).
+	Module        string `json:"module,omitempty"`
+	ModuleVersion string `json:"module_version,omitempty"`
+	// VCSRevision and VCSModified are the version control stamp of the
+	// build, when it has one.
+	VCSRevision string `json:"vcs_revision,omitempty"`
+	VCSModified bool   `json:"vcs_modified,omitempty"`
+	// Goroutines is the stack dump of all goroutines in runtime.Stack
+	// format, set only by [Builder.GoroutineDump].
+	Goroutines string `json:"goroutines,omitempty"`
+}
+
+// Diagnostics attaches a [Diagnostics] snapshot of the process to the
+// issued error: goroutine ID and count, GOMAXPROCS, heap statistics from
+// runtime/metrics, and the build's module version and VCS revision.
+// Wrapping inherits the snapshot, and like the stack trace the deepest
+// one wins: an outer Diagnostics is a no-op when the chain already has one.
+func (b *Builder) Diagnostics() *Builder {
+	if b.diagnostics == diagNone {
+		b.diagnostics = diagSnapshot
+	}
+	return b
+}
+
+// GoroutineDump attaches a [Diagnostics] snapshot like Diagnostics, adding
+// the stacks of all goroutines. The dump stops the world while it is
+// taken and can be large, so reserve it for rare, hard-to-reproduce
+// failures.
+func (b *Builder) GoroutineDump() *Builder {
+	b.diagnostics = diagGoroutines
+	return b
+}
+
+// Diagnostics returns the snapshot attached with [Builder.Diagnostics] or
+// [Builder.GoroutineDump], or nil when there is none. For an error
+// decoded with [ParseJSON] it is the sender's snapshot. Callers must treat
+// it as read-only.
+func (e *Error) Diagnostics() *Diagnostics {
+	if e == nil {
+		return nil
+	}
+	return e.diag
+}
+
+// diagLevel is what a builder attaches at finalize.
+type diagLevel uint8
+
+const (
+	diagNone diagLevel = iota
+	diagSnapshot
+	diagGoroutines
+)
+
+// maxGoroutineDump caps the goroutine dump; longer dumps are cut and end
+// with a note.
+const maxGoroutineDump = 8 << 20
+
+// diagMetrics are the runtime/metrics samples a snapshot reads, in the
+// order snapshot assigns them.
+var diagMetrics = []string{
+	"/memory/classes/heap/objects:bytes",
+	"/gc/heap/objects:objects",
+	"/gc/heap/goal:bytes",
+	"/gc/cycles/total:gc-cycles",
+}
+
+// snapshot takes a Diagnostics of the calling goroutine and the process.
+func snapshot(level diagLevel) *Diagnostics {
+	d := &Diagnostics{
+		GoroutineID:  goroutineID(),
+		NumGoroutine: runtime.NumGoroutine(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+	}
+	samples := make([]metrics.Sample, len(diagMetrics))
+	for i, name := range diagMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	vals := [...]*uint64{&d.HeapAlloc, &d.HeapObjects, &d.HeapGoal, &d.GCCycles}
+	for i, s := range samples {
+		if s.Value.Kind() == metrics.KindUint64 {
+			*vals[i] = s.Value.Uint64()
+		}
+	}
+	bi := buildStamp()
+	d.GoVersion, d.Module, d.ModuleVersion = bi.GoVersion, bi.Module, bi.ModuleVersion
+	d.VCSRevision, d.VCSModified = bi.VCSRevision, bi.VCSModified
+	if level == diagGoroutines {
+		d.Goroutines = goroutineDump()
+	}
+	return d
+}
+
+// buildStamp holds the build fields of a snapshot, read once.
+var buildStamp = sync.OnceValue(func() Diagnostics {
+	var d Diagnostics
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		d.GoVersion = runtime.Version()
+		return d
+	}
+	d.GoVersion = bi.GoVersion
+	d.Module, d.ModuleVersion = bi.Main.Path, bi.Main.Version
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			d.VCSRevision = s.Value
+		case "vcs.modified":
+			d.VCSModified = s.Value == "true"
+		}
+	}
+	return d
+})
+
+// goroutineID parses the calling goroutine's ID from the header of its
+// stack ("goroutine 18 [running]:"), or returns 0.
+func goroutineID() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b, ok := bytes.CutPrefix(b, []byte("goroutine "))
+	if !ok {
+		return 0
+	}
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// goroutineDump returns the stacks of all goroutines, cut at
+// maxGoroutineDump.
+func goroutineDump() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		if len(buf) >= maxGoroutineDump {
+			return string(buf[:n]) + "\n... goroutine dump truncated"
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// writeText writes d as "key=value" lines, each preceded by a newline and
+// indent, with the goroutine dump indented one level further.
+func (d *Diagnostics) writeText(w io.Writer, indent string) {
+	for _, a := range d.logAttrs() {
+		if a.Key != "goroutines" {
+			fmt.Fprintf(w, "\n%s%s=%v", indent, a.Key, a.Value)
+			continue
+		}
+		fmt.Fprintf(w, "\n%sgoroutines:", indent)
+		for _, line := range strings.Split(strings.TrimRight(d.Goroutines, "\n"), "\n") {
+			io.WriteString(w, "\n")
+			if line != "" {
+				io.WriteString(w, indent+"    "+line)
+			}
+		}
+	}
+}
+
+// logAttrs renders d as the attributes of the "diagnostics" slog group.
+func (d *Diagnostics) logAttrs() []slog.Attr {
+	out := make([]slog.Attr, 0, 13)
+	if d.GoroutineID != 0 {
+		out = append(out, slog.Int64("goroutine_id", d.GoroutineID))
+	}
+	out = append(out,
+		slog.Int("num_goroutine", d.NumGoroutine),
+		slog.Int("gomaxprocs", d.GOMAXPROCS),
+		slog.Uint64("heap_alloc_bytes", d.HeapAlloc),
+		slog.Uint64("heap_objects", d.HeapObjects),
+		slog.Uint64("heap_goal_bytes", d.HeapGoal),
+		slog.Uint64("gc_cycles", d.GCCycles),
+	)
+	for _, kv := range [...][2]string{
+		{"go_version", d.GoVersion},
+		{"module", d.Module},
+		{"module_version", d.ModuleVersion},
+		{"vcs_revision", d.VCSRevision},
+	} {
+		if kv[1] != "" {
+			out = append(out, slog.String(kv[0], kv[1]))
+		}
+	}
+	if d.VCSModified {
+		out = append(out, slog.Bool("vcs_modified", true))
+	}
+	if d.Goroutines != "" {
+		out = append(out, slog.String("goroutines", d.Goroutines))
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package aerr_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+func TestDiagnosticsSnapshot(t *testing.T) {
+	e, _ := aerr.AsAerr(aerr.Code("DIAG").Diagnostics().Err(nil))
+	d := e.Diagnostics()
+	if d == nil {
+		t.Fatal("Diagnostics() = nil")
+	}
+	if d.GoroutineID <= 0 || d.NumGoroutine <= 0 || d.GOMAXPROCS <= 0 {
+		t.Errorf("runtime fields not set: %+v", d)
+	}
+	if d.HeapAlloc == 0 || d.HeapObjects == 0 || d.GoVersion == "" {
+		t.Errorf("heap or build fields not set: %+v", d)
+	}
+	if d.Goroutines != "" {
+		t.Error("Diagnostics() must not dump goroutines")
+	}
+	if e.NumAttrs() != 0 {
+		t.Errorf("snapshot leaked into attributes: %v", e.Attributes())
+	}
+}
+
+func TestDiagnosticsOffByDefault(t *testing.T) {
+	e, _ := aerr.AsAerr(aerr.Code("PLAIN").Err(nil))
+	if e.Diagnostics() != nil {
+		t.Error("Diagnostics() set without the builder option")
+	}
+	data, _ := json.Marshal(e)
+	if strings.Contains(string(data), "diagnostics") {
+		t.Errorf("JSON = %s", data)
+	}
+}
+
+func TestGoroutineDump(t *testing.T) {
+	e, _ := aerr.AsAerr(aerr.Code("DUMP").GoroutineDump().Err(nil))
+	d := e.Diagnostics()
+	if d == nil || !strings.HasPrefix(d.Goroutines, "goroutine ") || !strings.Contains(d.Goroutines, "TestGoroutineDump") {
+		t.Fatalf("goroutine dump missing or lacks the test goroutine: %+v", d)
+	}
+}
+
+func TestDiagnosticsDeepestWins(t *testing.T) {
+	inner := aerr.Code("INNER").Diagnostics().Err(nil)
+	ie, _ := aerr.AsAerr(inner)
+	oe, _ := aerr.AsAerr(aerr.Code("OUTER").GoroutineDump().Wrap(inner))
+	if oe.Diagnostics() != ie.Diagnostics() {
+		t.Error("outer layer replaced the inherited snapshot")
+	}
+}
+
+func TestDiagnosticsRendering(t *testing.T) {
+	err := aerr.Code("DIAG").With("k", "v").Diagnostics().Err(nil)
+	e, _ := aerr.AsAerr(err)
+
+	data, _ := json.Marshal(e)
+	want, _ := json.Marshal(e.Diagnostics())
+	if !strings.HasSuffix(string(data), `,"diagnostics":`+string(want)+"}") {
+		t.Errorf("JSON = %s, want diagnostics last", data)
+	}
+	back, perr := aerr.ParseJSON(data)
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	be, _ := aerr.AsAerr(back)
+	if got := be.Diagnostics(); got == nil || *got != *e.Diagnostics() {
+		t.Errorf("ParseJSON diagnostics = %+v, want %+v", got, e.Diagnostics())
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("failed", "err", err)
+	var line struct {
+		Err struct {
+			Attributes  map[string]any   `json:"attributes"`
+			Diagnostics aerr.Diagnostics `json:"diagnostics"`
+		} `json:"err"`
+	}
+	if jerr := json.Unmarshal(buf.Bytes(), &line); jerr != nil {
+		t.Fatal(jerr)
+	}
+	if line.Err.Diagnostics != *e.Diagnostics() || len(line.Err.Attributes) != 1 {
+		t.Errorf("slog output = %s", buf.Bytes())
+	}
+
+	if out := fmt.Sprintf("%+v", err); !strings.Contains(out, "\ndiagnostics:\n    goroutine_id=") {
+		t.Errorf("%%+v = %q", out)
+	}
+}
@@ -6,7 +6,11 @@
 // times. The [Error] type implements error, slog.LogValuer, json.Marshaler,
 // and fmt.Formatter, so a single value logs cleanly through log/slog,
 // marshals to JSON, and prints with %+v — while remaining fully compatible
-// with errors.Is, errors.As, and errors.Unwrap.
+// with errors.Is, errors.As, and errors.Unwrap. [EncodeJSON] and
+// [ParseJSON] carry an error across a process boundary and back.
+// [SetSchema] renames the rendered keys to a backend's conventions, such
+// as [OTelSchema], and [FlatAttrs] spreads them as dotted top-level keys
+// for backends that index only top-level fields.
 //
 // # Building errors
 //
@@ -24,7 +28,39 @@
 //	slog.Error("request failed", slog.Any("err", err))
 //
 // Stack capture is opt-in: it happens only when StackTrace() is requested,
-// and at most once per chain (see below).
+// and at most once per chain (see below). [SetCapturePolicy] moves the
+// decision to a central policy with per-code rules and sampling.
+//
+// A capture records at most [DefaultStackDepth] frames unless
+// [SetStackDepth] or [Builder.StackTraceDepth] choose another depth; a
+// truncated trace ends with a "... N more frames" entry. Rendered traces
+// hide the standard library and aerr's own frames; [SetFramePolicy]
+// adjusts which frames are kept and trims their file paths. Functions
+// marked with [Helper] are skipped at the top of a captured stack. A layer
+// wrapping an error from github.com/go-errors/errors or a similar library
+// adopts that error's stack instead of capturing its own; a
+// [StackExtractor] teaches it other stack types, such as
+// github.com/pkg/errors'.
+//
+// [SetReturnTrace] records a cheaper alternative to a full stack: the call
+// site of every layer, read with [Error.WrappedAt]. [SetRawStacks] logs
+// raw PCs and the build ID instead of rendered traces, for symbolizing
+// offline with cmd/aerr-symbolize. For local development,
+// [SetSourceContext] adds source snippets to %+v and [WriteDev].
+// [Builder.Diagnostics] attaches a snapshot of the process (goroutines,
+// heap, build) that renders as its own "diagnostics" object.
+//
+// # Error kinds
+//
+// [Define] registers a code once and returns a [Kind], a concurrency-safe
+// template that produces builders and matches issued errors with
+// errors.Is. Duplicate codes panic at init:
+//
+//	var ErrUserNotFound = aerr.Define("USER_NOT_FOUND",
+//		aerr.KindMessage("user not found"))
+//
+//	err := ErrUserNotFound.With("user_id", id).Err(nil)
+//	errors.Is(err, ErrUserNotFound) // true
 //
 // # Redacting attributes
 //
@@ -58,12 +94,36 @@
 //     the outer builder set none.
 //   - Attributes: outer attributes win; inner attributes are appended when
 //     their keys are not already present, preserving order.
+//     [Builder.MergePolicy] and [SetMergePolicy] select another resolution
+//     for conflicting keys.
 //   - Stack trace: the deepest stack wins. If the wrapped chain already
 //     carries a trace it is inherited and an outer StackTrace() is a no-op,
 //     so each chain captures at most once and traces point at the origin.
+//     Under [SetStackMode]([StackPerLayer]) outer layers that request a
+//     stack keep their own as well, read with [Error.StackSections].
 //
 // Metadata is absorbed from the nearest inner [Error] in the chain even
-// through non-aerr wrappers such as fmt.Errorf with %w.
+// through non-aerr wrappers such as fmt.Errorf with %w. Non-aerr errors
+// contribute a code and attributes of their own by implementing [Coder]
+// and [Attributer]; [From] gives the flattened view of a foreign error
+// logged without an aerr wrapper.
+//
+// [Layers] reverses the flattening for inspection, reporting what each
+// aerr layer contributed on its own.
+//
+// # Process-wide configuration
+//
+// Behavior that a codebase settles once is configured with process-global
+// setters rather than per builder: [SetMergePolicy], [SetStackDepth],
+// [SetCapturePolicy], [SetStackMode], [SetFramePolicy], [RegisterHelpers],
+// [SetReturnTrace], [SetRawStacks], [SetSourceContext], [SetSchema],
+// [SetFingerprintOptions], [SetTraceExtractor], [SetStackExtractor], and
+// [RedactKeys]. Each is independent and safe for concurrent use, but they
+// are meant to be called from main at startup, before errors are issued
+// or logged: a setting applies to errors finalized or rendered after it,
+// and a trace already rendered stays cached. Where a builder method
+// overrides a setting (such as [Builder.MergePolicy] or
+// [Builder.StackTraceDepth]), the builder wins.
 //
 // # Concurrency
 //
@@ -79,4 +139,8 @@
 //
 //   - github.com/tafaquh/aerr/zerolog — github.com/rs/zerolog integration
 //   - github.com/tafaquh/aerr/zap — go.uber.org/zap integration
+//
+// The stdlib-only subpackage github.com/tafaquh/aerr/http renders errors as
+// RFC 7807 problem+json responses, and github.com/tafaquh/aerr/slog wraps a
+// slog.Handler to lift codes, adjust levels, and drop expected stacks.
 package aerr
@@ -108,3 +108,18 @@ func ExampleRedactKeys() {
 	// {"code":"AUTH","message":"login failed","attributes":{"user":"alice","password":"[REDACTED]"}}
 	// hunter2
 }
+
+// ExampleDefine declares a reusable error kind and matches issued errors
+// against it with errors.Is, even behind an outer layer with its own code.
+func ExampleDefine() {
+	errNotFound := aerr.Define("EXAMPLE_NOT_FOUND", aerr.KindMessage("user not found"))
+
+	err := errNotFound.With("user_id", 42).Err(nil)
+	wrapped := aerr.Code("HANDLER").Message("get user").Wrap(err)
+
+	fmt.Println(wrapped)
+	fmt.Println(errors.Is(wrapped, errNotFound))
+	// Output:
+	// get user: user not found
+	// true
+}
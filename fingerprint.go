@@ -0,0 +1,171 @@
+package aerr
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultFingerprintFrames is the number of top stack frames a fingerprint
+// hashes when FingerprintOptions.Frames is zero.
+const DefaultFingerprintFrames = 3
+
+// FingerprintOptions selects the inputs of [Error.Fingerprint] and whether
+// renderers emit it. The zero value hashes the code, the normalized
+// message, and the top DefaultFingerprintFrames frames, and emits nothing.
+type FingerprintOptions struct {
+	// Frames is the number of top user frames (as reported by Frames)
+	// whose function names are hashed. Zero means
+	// DefaultFingerprintFrames; a negative value hashes no frames.
+	Frames int
+	// IgnoreCode leaves the code out of the hash.
+	IgnoreCode bool
+	// IgnoreMessage leaves the message out of the hash.
+	IgnoreMessage bool
+	// Emit adds a "fingerprint" key to LogValue, MarshalJSON, and the zap
+	// and zerolog adapters.
+	Emit bool
+}
+
+// fingerprintOptions holds the process-global FingerprintOptions; nil
+// means the zero value.
+var fingerprintOptions atomic.Pointer[FingerprintOptions]
+
+// SetFingerprintOptions installs the process-global fingerprint options
+// used by [Error.Fingerprint] and the renderers.
+func SetFingerprintOptions(o FingerprintOptions) {
+	fingerprintOptions.Store(&o)
+}
+
+// EmitsFingerprint reports whether renderers should add the "fingerprint"
+// key, as set by FingerprintOptions.Emit. Adapters outside this package
+// consult it so every render path agrees.
+func EmitsFingerprint() bool {
+	o := fingerprintOptions.Load()
+	return o != nil && o.Emit
+}
+
+// Fingerprint returns a stable 16-hex-digit hash identifying the kind of
+// failure e represents, for alert grouping and deduplication, using the
+// options installed with [SetFingerprintOptions]. See FingerprintWith.
+// The result is computed once per installed options and cached.
+func (e *Error) Fingerprint() string {
+	if e == nil {
+		return ""
+	}
+	p := fingerprintOptions.Load()
+	if c := e.fingerprint.Load(); c != nil && c.opts == p {
+		return c.fp
+	}
+	var o FingerprintOptions
+	if p != nil {
+		o = *p
+	}
+	fp := e.FingerprintWith(o)
+	e.fingerprint.Store(&fingerprintCache{opts: p, fp: fp})
+	return fp
+}
+
+// fingerprintCache is a fingerprint with the installed options it was
+// computed under; SetFingerprintOptions stores a new pointer each time.
+type fingerprintCache struct {
+	opts *FingerprintOptions
+	fp   string
+}
+
+// FingerprintWith returns e's fingerprint computed from the inputs o
+// selects (o.Emit is ignored). The hash covers the code, the message with
+// every attribute value that appears in it as a whole token replaced by
+// "{key}" — so "user 42 not found" with user_id=42 and "user 7 not found"
+// with user_id=7 group together, while count=2 leaves "v2 API" alone —
+// and the function names (not line numbers)
+// of the top stack frames. Errors that differ only in attribute values
+// therefore share a fingerprint. It returns "" for a nil *Error.
+func (e *Error) FingerprintWith(o FingerprintOptions) string {
+	if e == nil {
+		return ""
+	}
+	h := fnv.New64a()
+	if !o.IgnoreCode {
+		h.Write([]byte(e.code))
+	}
+	h.Write([]byte{0})
+	if !o.IgnoreMessage {
+		h.Write([]byte(e.normalizedMessage()))
+	}
+	h.Write([]byte{0})
+	n := o.Frames
+	if n == 0 {
+		n = DefaultFingerprintFrames
+	}
+	if n > 0 {
+		for i, f := range e.Frames() {
+			if i == n {
+				break
+			}
+			h.Write([]byte(f.Function))
+			h.Write([]byte{0})
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// normalizedMessage returns e's message with attribute values replaced by
+// "{key}" placeholders where they stand as whole tokens, not touching a
+// letter or digit on either side. Longer values are tried first, so a
+// value containing another is replaced whole. Redacted values are matched
+// by their plaintext.
+func (e *Error) normalizedMessage() string {
+	if len(e.attrs) == 0 || e.msg == "" {
+		return e.msg
+	}
+	type repl struct{ val, key string }
+	rs := make([]repl, 0, len(e.attrs))
+	for _, a := range e.attrs {
+		v := a.val
+		if r, ok := v.(Redacted); ok {
+			v = r.Value()
+		}
+		if s := fmt.Sprint(v); s != "" {
+			rs = append(rs, repl{val: s, key: a.key})
+		}
+	}
+	sort.SliceStable(rs, func(i, j int) bool { return len(rs[i].val) > len(rs[j].val) })
+	msg := e.msg
+	var b strings.Builder
+	done := 0
+	for i := 0; i < len(msg); i++ {
+		if prev, _ := utf8.DecodeLastRuneInString(msg[:i]); i > 0 && isWordRune(prev) {
+			continue
+		}
+		for _, r := range rs {
+			end := i + len(r.val)
+			if !strings.HasPrefix(msg[i:], r.val) {
+				continue
+			}
+			if next, _ := utf8.DecodeRuneInString(msg[end:]); end < len(msg) && isWordRune(next) {
+				continue
+			}
+			b.WriteString(msg[done:i])
+			b.WriteString("{" + r.key + "}")
+			done = end
+			i = end - 1
+			break
+		}
+	}
+	if done == 0 {
+		return msg
+	}
+	b.WriteString(msg[done:])
+	return b.String()
+}
+
+// isWordRune reports whether r is a letter or digit, which a replaced
+// value must not touch.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,119 @@
+package aerr_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// notFound builds an error whose message embeds an attribute value, from a
+// single call site so the stack is the same for every id.
+func notFound(id int) *aerr.Error {
+	err := aerr.Code("NOT_FOUND").
+		Messagef("user %d not found", id).
+		StackTrace().
+		With("user_id", id).
+		Err(nil)
+	e, _ := aerr.AsAerr(err)
+	return e
+}
+
+func TestFingerprintGroupsVaryingIDs(t *testing.T) {
+	a, b := notFound(42), notFound(1337)
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("fingerprints differ for the same failure: %s vs %s", a.Fingerprint(), b.Fingerprint())
+	}
+	if len(a.Fingerprint()) != 16 {
+		t.Errorf("Fingerprint() = %q, want 16 hex digits", a.Fingerprint())
+	}
+	if a.Fingerprint() != a.Fingerprint() {
+		t.Error("Fingerprint() is not deterministic")
+	}
+}
+
+func TestFingerprintReplacesWholeTokens(t *testing.T) {
+	fp := func(count int) string {
+		e, _ := aerr.AsAerr(aerr.Code("QUOTA").Messagef("v2 API: %d requests over", count).With("count", count).Err(nil))
+		return e.FingerprintWith(aerr.FingerprintOptions{Frames: -1})
+	}
+	if fp(2) != fp(7) {
+		t.Error("a short value rewrote part of another word")
+	}
+}
+
+func TestFingerprintCachedPerOptions(t *testing.T) {
+	defer aerr.SetFingerprintOptions(aerr.FingerprintOptions{})
+	e := notFound(42)
+	first := e.Fingerprint()
+	aerr.SetFingerprintOptions(aerr.FingerprintOptions{IgnoreCode: true})
+	if e.Fingerprint() == first {
+		t.Error("Fingerprint() kept the value cached under other options")
+	}
+	if allocs := testing.AllocsPerRun(10, func() { e.Fingerprint() }); allocs != 0 {
+		t.Errorf("cached Fingerprint() allocates %v times", allocs)
+	}
+}
+
+func TestFingerprintDistinguishes(t *testing.T) {
+	base := notFound(1)
+	otherCode, _ := aerr.AsAerr(aerr.Code("GONE").Messagef("user %d not found", 1).With("user_id", 1).Err(nil))
+	otherMsg, _ := aerr.AsAerr(aerr.Code("NOT_FOUND").Messagef("user %d deleted", 1).With("user_id", 1).Err(nil))
+	if base.FingerprintWith(aerr.FingerprintOptions{Frames: -1}) == otherCode.FingerprintWith(aerr.FingerprintOptions{Frames: -1}) {
+		t.Error("different codes share a fingerprint")
+	}
+	if base.FingerprintWith(aerr.FingerprintOptions{Frames: -1}) == otherMsg.FingerprintWith(aerr.FingerprintOptions{Frames: -1}) {
+		t.Error("different messages share a fingerprint")
+	}
+
+	// Frames are part of the default inputs: the same failure raised from a
+	// different function differs unless frames are excluded.
+	elsewhere := func() *aerr.Error {
+		e, _ := aerr.AsAerr(aerr.Code("NOT_FOUND").Messagef("user %d not found", 9).StackTrace().With("user_id", 9).Err(nil))
+		return e
+	}()
+	if base.Fingerprint() == elsewhere.Fingerprint() {
+		t.Error("different call sites share a fingerprint")
+	}
+	noFrames := aerr.FingerprintOptions{Frames: -1}
+	if base.FingerprintWith(noFrames) != elsewhere.FingerprintWith(noFrames) {
+		t.Error("Frames: -1 still distinguishes call sites")
+	}
+}
+
+func TestFingerprintIgnoreMessage(t *testing.T) {
+	a, _ := aerr.AsAerr(aerr.Code("X").Message("one").Err(nil))
+	b, _ := aerr.AsAerr(aerr.Code("X").Message("two").Err(nil))
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Error("messages without attributes should still distinguish")
+	}
+	o := aerr.FingerprintOptions{IgnoreMessage: true}
+	if a.FingerprintWith(o) != b.FingerprintWith(o) {
+		t.Error("IgnoreMessage still hashes the message")
+	}
+}
+
+func TestFingerprintEmit(t *testing.T) {
+	e := notFound(5)
+	raw, _ := json.Marshal(e)
+	if strings.Contains(string(raw), `"fingerprint":`) {
+		t.Errorf("fingerprint emitted by default: %s", raw)
+	}
+
+	aerr.SetFingerprintOptions(aerr.FingerprintOptions{Emit: true})
+	defer aerr.SetFingerprintOptions(aerr.FingerprintOptions{})
+
+	if !aerr.EmitsFingerprint() {
+		t.Fatal("EmitsFingerprint() = false after enabling")
+	}
+	raw, _ = json.Marshal(e)
+	want := fmt.Sprintf(`"fingerprint":%q`, e.Fingerprint())
+	if !strings.Contains(string(raw), want) {
+		t.Errorf("MarshalJSON = %s, want %s", raw, want)
+	}
+	if got := logValueKeys(e.LogValue()); !strings.Contains(strings.Join(got, ","), "code,fingerprint") {
+		t.Errorf("LogValue keys = %v, want fingerprint after code", got)
+	}
+}
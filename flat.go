@@ -0,0 +1,153 @@
+package aerr
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FlatOptions configures the flattened rendering of [FlatAttrs] and
+// [FlatValue]. The zero value produces keys such as "err.code" and
+// "err.attributes.user_id".
+type FlatOptions struct {
+	// Prefix is the first segment of every key; "err" when empty.
+	Prefix string
+	// Separator joins key segments; "." when empty.
+	Separator string
+}
+
+// FlatAttrs renders err as top-level attributes with dotted keys, for
+// log backends that index only top-level fields (Loki labels, some
+// SIEMs):
+//
+//	err.code=NOT_FOUND err.message="load user: no row"
+//	err.attributes.user_id=7 err.stacktrace.0="/app/repo.go:42 (repo.Find)"
+//
+// The segments after the prefix are the keys of the nested rendering,
+// named by [SetSchema] and emitted under the same conditions; list entries
+// are keyed by index, and map-valued attributes (such as groups from an
+// [Attributer]) are flattened further in key order. Spread the result
+// into a log call:
+//
+//	logger.LogAttrs(ctx, slog.LevelError, "failed", aerr.FlatAttrs(err, aerr.FlatOptions{})...)
+//
+// An error without an *Error in its chain, and contributing nothing
+// through [Coder] or [Attributer], renders as its message alone. A nil err
+// renders as no attributes.
+func FlatAttrs(err error, o FlatOptions) []slog.Attr {
+	f := flattener{prefix: o.Prefix, sep: o.Separator, s: currentSchema()}
+	if f.prefix == "" {
+		f.prefix = "err"
+	}
+	if f.sep == "" {
+		f.sep = "."
+	}
+	e, ok := From(err)
+	if !ok {
+		if msg, ok := errString(err); ok {
+			return []slog.Attr{slog.String(f.key(f.s.Message), msg)}
+		}
+		return nil
+	}
+	f.flatten(e)
+	return f.out
+}
+
+// FlatValue returns [FlatAttrs] as a group value. Logged under the empty
+// key, which slog handlers inline, it spreads the attributes at the top
+// level of the record:
+//
+//	logger.Error("failed", slog.Any("", aerr.FlatValue(err, aerr.FlatOptions{})))
+func FlatValue(err error, o FlatOptions) slog.Value {
+	return slog.GroupValue(FlatAttrs(err, o)...)
+}
+
+// flattener accumulates the flat attributes of one error.
+type flattener struct {
+	prefix, sep string
+	s           *Schema
+	out         []slog.Attr
+}
+
+// key joins the prefix and segments with the separator.
+func (f *flattener) key(segs ...string) string {
+	return f.prefix + f.sep + strings.Join(segs, f.sep)
+}
+
+func (f *flattener) add(key string, v slog.Value) {
+	f.out = append(f.out, slog.Attr{Key: key, Value: v})
+}
+
+// list adds one attribute per entry of vals, keyed by index under key.
+func (f *flattener) list(key string, vals []string) {
+	for i, v := range vals {
+		f.add(key+f.sep+strconv.Itoa(i), slog.StringValue(v))
+	}
+}
+
+// value adds v under key, flattening maps in key order.
+func (f *flattener) value(key string, v any) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		f.add(key, slog.AnyValue(v))
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f.value(key+f.sep+k, m[k])
+	}
+}
+
+// flatten adds e's attributes in the order of the nested rendering.
+func (f *flattener) flatten(e *Error) {
+	s := f.s
+	if e.code != "" {
+		f.add(f.key(s.Code), slog.StringValue(e.code))
+	}
+	if e.msg != "" {
+		f.add(f.key(s.Message), slog.StringValue(e.msg))
+	}
+	if EmitsFingerprint() {
+		f.add(f.key(s.Fingerprint), slog.StringValue(e.Fingerprint()))
+	}
+	for _, a := range e.attrs {
+		f.value(f.key(s.Attributes, a.key), a.val)
+	}
+	if raw, ok := e.rawStack(); ok {
+		if raw.BuildID != "" {
+			f.add(f.key("stack_raw", "build_id"), slog.StringValue(raw.BuildID))
+		}
+		f.add(f.key("stack_raw", "anchor"), slog.StringValue(hexAddr(raw.Anchor)))
+		f.list(f.key("stack_raw", "pcs"), raw.HexPCs())
+		if raw.Truncated > 0 {
+			f.add(f.key("stack_raw", "truncated"), slog.IntValue(raw.Truncated))
+		}
+	} else if traces := e.Traces(); len(traces) > 0 {
+		if s.StackString {
+			f.add(f.key(s.Stacktrace), slog.StringValue(strings.Join(traces, "\n")))
+		} else {
+			f.list(f.key(s.Stacktrace), traces)
+		}
+	}
+	for i, sec := range e.layerSections() {
+		key := f.key(s.LayerStacks, strconv.Itoa(i))
+		if sec.Code != "" {
+			f.add(key+f.sep+"code", slog.StringValue(sec.Code))
+		}
+		if sec.Message != "" {
+			f.add(key+f.sep+"message", slog.StringValue(sec.Message))
+		}
+		f.list(key+f.sep+"stacktrace", sec.Traces)
+	}
+	f.list(f.key(s.WrappedAt), e.WrappedAt())
+	if e.diag != nil {
+		for _, a := range e.diag.logAttrs() {
+			f.add(f.key(s.Diagnostics, a.Key), a.Value)
+		}
+	}
+}
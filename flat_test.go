@@ -0,0 +1,103 @@
+package aerr_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// flatMap collects attrs into a map keyed by attribute key, resolving
+// LogValuers the way a handler would.
+func flatMap(attrs []slog.Attr) map[string]any {
+	m := make(map[string]any, len(attrs))
+	for _, a := range attrs {
+		m[a.Key] = a.Value.Resolve().Any()
+	}
+	return m
+}
+
+func TestFlatAttrs(t *testing.T) {
+	err := aerr.Code("NOT_FOUND").
+		Message("load user").
+		With("user_id", 7).
+		With("password", aerr.Redact("hunter2")).
+		With("req", map[string]any{"method": "GET", "path": "/u/7"}).
+		StackTrace().
+		Wrap(errors.New("no row"))
+	e, _ := aerr.AsAerr(err)
+
+	attrs := aerr.FlatAttrs(err, aerr.FlatOptions{})
+	got := flatMap(attrs)
+	want := map[string]any{
+		"err.code":                  "NOT_FOUND",
+		"err.message":               "load user: no row",
+		"err.attributes.user_id":    int64(7),
+		"err.attributes.password":   aerr.RedactedText,
+		"err.attributes.req.method": "GET",
+		"err.attributes.req.path":   "/u/7",
+		"err.stacktrace.0":          e.Traces()[0],
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if attrs[0].Key != "err.code" || attrs[1].Key != "err.message" {
+		t.Errorf("first keys = %s, %s; want code then message", attrs[0].Key, attrs[1].Key)
+	}
+	if _, ok := got["err.attributes.req"]; ok {
+		t.Error("map attribute emitted unflattened")
+	}
+}
+
+func TestFlatOptionsPrefixAndSeparator(t *testing.T) {
+	err := aerr.Code("C").With("k", "v").Err(nil)
+	got := flatMap(aerr.FlatAttrs(err, aerr.FlatOptions{Prefix: "error", Separator: "_"}))
+	if got["error_code"] != "C" || got["error_attributes_k"] != "v" || len(got) != 2 {
+		t.Errorf("FlatAttrs = %v", got)
+	}
+}
+
+func TestFlatAttrsPlainError(t *testing.T) {
+	got := aerr.FlatAttrs(errors.New("boom"), aerr.FlatOptions{})
+	if len(got) != 1 || got[0].Key != "err.message" || got[0].Value.String() != "boom" {
+		t.Errorf("FlatAttrs(plain) = %v", got)
+	}
+	if got := aerr.FlatAttrs(nil, aerr.FlatOptions{}); got != nil {
+		t.Errorf("FlatAttrs(nil) = %v, want nil", got)
+	}
+}
+
+func TestFlatValueInlinesInSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	err := aerr.Code("NOT_FOUND").Message("gone").Err(nil)
+	logger.Error("failed", slog.Any("", aerr.FlatValue(err, aerr.FlatOptions{})))
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["err.code"] != "NOT_FOUND" || line["err.message"] != "gone" {
+		t.Errorf("log line = %s", buf.Bytes())
+	}
+}
+
+func TestFlatAttrsFollowSchema(t *testing.T) {
+	defer aerr.SetSchema(aerr.DefaultSchema())
+	aerr.SetSchema(aerr.OTelSchema())
+
+	err := aerr.Code("C").StackTrace().Err(nil)
+	e, _ := aerr.AsAerr(err)
+	got := flatMap(aerr.FlatAttrs(err, aerr.FlatOptions{}))
+	if got["err.exception.type"] != "C" {
+		t.Errorf("FlatAttrs = %v", got)
+	}
+	if _, ok := got["err.exception.stacktrace"]; !ok || len(e.Traces()) == 0 {
+		t.Errorf("stack not a single key under OTelSchema: %v", got)
+	}
+}
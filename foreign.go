@@ -0,0 +1,103 @@
+package aerr
+
+import "sync/atomic"
+
+// foreignStack returns the stack captured by the innermost non-aerr error
+// in err's chain that exposes one, so a layer wrapping it can adopt the
+// origin trace instead of capturing a shallower one at the wrap site. It
+// returns nil when there is none. An error exposes a stack through one of
+// these methods, or through the installed [StackExtractor]:
+//
+//   - Callers() []uintptr, the raw PCs (github.com/go-errors/errors and
+//     others);
+//   - StackTrace() []uintptr.
+//
+// In all cases the entries must be runtime.Callers return addresses,
+// which is what these libraries record.
+func foreignStack(err error) []uintptr {
+	var pcs []uintptr
+	walkChain(err, func(err error) bool {
+		if _, ok := err.(*Error); ok {
+			return false
+		}
+		if p := stackOf(err); len(p) > 0 {
+			pcs = p
+		}
+		return false
+	})
+	return pcs
+}
+
+// StackExtractor returns the stack a foreign error carries as
+// runtime.Callers return addresses, or nil when it carries none. It lets
+// errors whose stack method returns a library-specific type be adopted
+// without the core module importing that library. For
+// github.com/pkg/errors:
+//
+//	aerr.SetStackExtractor(func(err error) []uintptr {
+//		if st, ok := err.(interface{ StackTrace() errors.StackTrace }); ok {
+//			return aerr.FramePCs(st.StackTrace())
+//		}
+//		return nil
+//	})
+type StackExtractor func(err error) []uintptr
+
+// stackExtractor holds the process-global StackExtractor; nil disables it.
+var stackExtractor atomic.Pointer[StackExtractor]
+
+// SetStackExtractor installs the process-global extractor consulted for
+// each foreign error that has neither a Callers nor a StackTrace method
+// returning []uintptr. SetStackExtractor(nil) removes it.
+func SetStackExtractor(fn StackExtractor) {
+	if fn == nil {
+		stackExtractor.Store(nil)
+		return
+	}
+	stackExtractor.Store(&fn)
+}
+
+// FramePCs converts a slice of uintptr-based frames, such as
+// github.com/pkg/errors' StackTrace, to the PCs a [StackExtractor]
+// returns.
+func FramePCs[F ~uintptr](frames []F) []uintptr {
+	if len(frames) == 0 {
+		return nil
+	}
+	pcs := make([]uintptr, len(frames))
+	for i, f := range frames {
+		pcs[i] = uintptr(f)
+	}
+	return pcs
+}
+
+// stackOf returns the PCs err exposes, or nil. A method or extractor that
+// panics counts as exposing none.
+func stackOf(err error) (pcs []uintptr) {
+	if isNilValue(err) {
+		return nil
+	}
+	defer func() {
+		if recover() != nil {
+			pcs = nil
+		}
+	}()
+	switch s := err.(type) {
+	case interface{ Callers() []uintptr }:
+		return append([]uintptr(nil), s.Callers()...)
+	case interface{ StackTrace() []uintptr }:
+		return append([]uintptr(nil), s.StackTrace()...)
+	}
+	if fn := stackExtractor.Load(); fn != nil {
+		return append([]uintptr(nil), (*fn)(err)...)
+	}
+	return nil
+}
+
+// clipStack limits an adopted stack to depth frames like captureStack,
+// reporting how many were cut.
+func clipStack(pcs []uintptr, depth int) ([]uintptr, int) {
+	if len(pcs) <= depth {
+		return pcs, 0
+	}
+	return pcs[:depth:depth], keptFrames(pcs[depth:])
+}
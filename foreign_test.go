@@ -0,0 +1,174 @@
+package aerr_test
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// pkgFrame and pkgStackTrace mirror github.com/pkg/errors' Frame and
+// StackTrace types.
+type (
+	pkgFrame      uintptr
+	pkgStackTrace []pkgFrame
+)
+
+type pkgError struct {
+	msg   string
+	stack []uintptr
+}
+
+func (e *pkgError) Error() string { return e.msg }
+
+func (e *pkgError) StackTrace() pkgStackTrace {
+	st := make(pkgStackTrace, len(e.stack))
+	for i, pc := range e.stack {
+		st[i] = pkgFrame(pc)
+	}
+	return st
+}
+
+// usePkgStacks installs the StackExtractor documented for
+// github.com/pkg/errors, for pkgStackTrace, until the test ends.
+func usePkgStacks(t *testing.T) {
+	aerr.SetStackExtractor(func(err error) []uintptr {
+		if st, ok := err.(interface{ StackTrace() pkgStackTrace }); ok {
+			return aerr.FramePCs(st.StackTrace())
+		}
+		return nil
+	})
+	t.Cleanup(func() { aerr.SetStackExtractor(nil) })
+}
+
+// rawStackError exposes its PCs through StackTrace() []uintptr.
+type rawStackError struct {
+	msg   string
+	stack []uintptr
+}
+
+func (e *rawStackError) Error() string         { return e.msg }
+func (e *rawStackError) StackTrace() []uintptr { return e.stack }
+
+// callersError mirrors github.com/go-errors/errors.
+type callersError struct {
+	msg   string
+	stack []uintptr
+}
+
+func (e *callersError) Error() string      { return e.msg }
+func (e *callersError) Callers() []uintptr { return e.stack }
+
+// causer links to its cause only through Cause, like errors from before
+// Unwrap existed.
+type causer struct {
+	msg   string
+	cause error
+}
+
+func (e *causer) Error() string { return e.msg + ": " + e.cause.Error() }
+func (e *causer) Cause() error  { return e.cause }
+
+type panickyStack struct{}
+
+func (panickyStack) Error() string             { return "panicky" }
+func (panickyStack) StackTrace() pkgStackTrace { panic("boom") }
+
+// callers records the stack from its caller upward.
+func callers() []uintptr {
+	pcs := make([]uintptr, 64)
+	return pcs[:runtime.Callers(2, pcs)]
+}
+
+func pkgOrigin() error     { return &pkgError{msg: "pkg origin", stack: callers()} }
+func callersOrigin() error { return &callersError{msg: "callers origin", stack: callers()} }
+func rawOrigin() error     { return &rawStackError{msg: "raw origin", stack: callers()} }
+
+func wrapWithStack(err error) error {
+	return aerr.Code("WRAPPED").StackTrace().Wrap(err)
+}
+
+func TestAdoptForeignStack(t *testing.T) {
+	usePkgStacks(t)
+	for name, tc := range map[string]struct {
+		origin func() error
+		fn     string
+	}{
+		"StackExtractor": {pkgOrigin, "pkgOrigin"},
+		"StackTrace":     {rawOrigin, "rawOrigin"},
+		"Callers":        {callersOrigin, "callersOrigin"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			e, _ := aerr.AsAerr(wrapWithStack(tc.origin()))
+			traces := e.Traces()
+			if len(traces) == 0 || !strings.HasSuffix(traces[0], "aerr_test."+tc.fn+")") {
+				t.Fatalf("Traces() = %q, want the foreign origin %s first", traces, tc.fn)
+			}
+			if !aerr.Layers(e)[0].CapturedStack {
+				t.Error("adopting layer does not report CapturedStack")
+			}
+		})
+	}
+}
+
+func TestForeignStackNeedsExtractor(t *testing.T) {
+	e, _ := aerr.AsAerr(wrapWithStack(pkgOrigin()))
+	if traces := e.Traces(); len(traces) == 0 || !strings.Contains(traces[0], "wrapWithStack") {
+		t.Errorf("Traces() = %q, want a local capture without an extractor", traces)
+	}
+}
+
+func TestAdoptForeignStackThroughCauseAndAerr(t *testing.T) {
+	usePkgStacks(t)
+	// A stackless aerr layer and a Cause-only wrapper between the foreign
+	// origin and the capturing layer.
+	inner := aerr.Code("INNER").Wrap(&causer{msg: "legacy", cause: pkgOrigin()})
+	e, _ := aerr.AsAerr(wrapWithStack(inner))
+	if traces := e.Traces(); len(traces) == 0 || !strings.Contains(traces[0], "pkgOrigin") {
+		t.Fatalf("Traces() = %q, want pkgOrigin first", traces)
+	}
+}
+
+func TestForeignStackNeedsRequest(t *testing.T) {
+	e, _ := aerr.AsAerr(aerr.Code("PLAIN").Wrap(pkgOrigin()))
+	if traces := e.Traces(); traces != nil {
+		t.Errorf("Traces() = %q without StackTrace(), want nil", traces)
+	}
+}
+
+func TestForeignStackDepth(t *testing.T) {
+	usePkgStacks(t)
+	e, _ := aerr.AsAerr(aerr.Code("D").StackTraceDepth(1).Wrap(pkgOrigin()))
+	if got := e.Frames(); len(got) != 1 || !strings.HasSuffix(got[0].Function, ".pkgOrigin") {
+		t.Errorf("Frames() = %+v, want only pkgOrigin", got)
+	}
+	if e.TruncatedFrames() == 0 {
+		t.Error("TruncatedFrames() = 0 for a clipped foreign stack")
+	}
+}
+
+func TestForeignStackPanicFallsBack(t *testing.T) {
+	usePkgStacks(t)
+	e, _ := aerr.AsAerr(wrapWithStack(panickyStack{}))
+	if traces := e.Traces(); len(traces) == 0 || !strings.Contains(traces[0], "wrapWithStack") {
+		t.Errorf("Traces() = %q, want a local capture", traces)
+	}
+}
+
+func TestCauseChains(t *testing.T) {
+	err := &causer{msg: "legacy", cause: aerr.Code("NOT_FOUND").Err(nil)}
+	if e, ok := aerr.AsAerr(err); !ok || e.Code() != "NOT_FOUND" {
+		t.Errorf("AsAerr through Cause = %v, %v", e, ok)
+	}
+	if !aerr.HasCode(err, "NOT_FOUND") {
+		t.Error("HasCode does not follow Cause")
+	}
+	if layers := aerr.Layers(err); len(layers) != 1 {
+		t.Errorf("Layers through Cause = %d layers, want 1", len(layers))
+	}
+	if aerr.HasCode(&causer{msg: "x", cause: errors.New("y")}, "NOT_FOUND") {
+		t.Error("HasCode matched a chain without the code")
+	}
+}
@@ -9,8 +9,12 @@ import (
 //
 //	%s, %v   the combined message (same as Error())
 //	%q       the combined message, quoted
-//	%+v      multi-line detail: message, code, attributes, and the stack
-//	         trace when one was captured (pkg/errors convention)
+//	%+v      multi-line detail: message, code, attributes, the stack
+//	         trace when one was captured (pkg/errors convention), with
+//	         source snippets under SetSourceContext, one more trace
+//	         section per outer layer under StackPerLayer, the return
+//	         trace when one was recorded, and the diagnostics snapshot
+//	         when one was attached
 func (e *Error) Format(s fmt.State, verb rune) {
 	if e == nil {
 		io.WriteString(s, "<nil>")
@@ -44,11 +48,48 @@ func (e *Error) formatDetailed(w io.Writer) {
 			fmt.Fprintf(w, "\n    %s=%v", a.key, a.val)
 		}
 	}
-	if traces := e.Traces(); len(traces) > 0 {
+	var traces []string
+	var src []Frame
+	n := int(sourceContext.Load())
+	if n > 0 {
+		traces, src = e.sourceTraces()
+	} else {
+		traces = e.Traces()
+	}
+	if len(traces) > 0 {
 		io.WriteString(w, "\nstacktrace:")
-		for _, fr := range traces {
+		for i, fr := range traces {
+			io.WriteString(w, "\n    ")
+			io.WriteString(w, fr)
+			if i < len(src) {
+				writeSnippet(w, "      ", src[i], n)
+			}
+		}
+	}
+	for _, sec := range e.layerSections() {
+		io.WriteString(w, "\nstacktrace (")
+		io.WriteString(w, sectionLabel(sec))
+		io.WriteString(w, "):")
+		for _, fr := range sec.Traces {
 			io.WriteString(w, "\n    ")
 			io.WriteString(w, fr)
 		}
 	}
+	if sites := e.WrappedAt(); len(sites) > 0 {
+		io.WriteString(w, "\nwrapped_at:")
+		for _, site := range sites {
+			io.WriteString(w, "\n    ")
+			io.WriteString(w, site)
+		}
+	}
+	if e.diag != nil {
+		io.WriteString(w, "\ndiagnostics:")
+		e.diag.writeText(w, "    ")
+	}
+}
+
+// sectionLabel names a stack section's layer in %+v output as
+// "CODE: message", either part omitted when empty.
+func sectionLabel(sec StackSection) string {
+	return joinMsg(sec.Code, sec.Message)
 }
@@ -0,0 +1,214 @@
+package aerr
+
+import (
+	"os"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// FramePolicy customizes which frames rendered stack traces keep and how
+// their file paths read. It applies to every render path alike: Traces,
+// Frames, %+v, JSON, slog, and the logging adapters. The zero value
+// behaves like the built-in rules, which drop aerr's own frames and the
+// standard library.
+//
+// Package prefixes match whole import-path elements: "github.com/go-chi/chi"
+// matches frames in github.com/go-chi/chi and github.com/go-chi/chi/v5 but
+// not github.com/go-chi/chizzle.
+type FramePolicy struct {
+	// Include lists package or module prefixes whose frames are kept even
+	// when Exclude or the built-in standard-library rule would drop them,
+	// such as "net/http" to show the server frames. aerr's own frames are
+	// always dropped.
+	Include []string
+	// Exclude lists package or module prefixes whose frames are dropped,
+	// such as a router or middleware framework.
+	Exclude []string
+	// Filter, when set, is consulted for every frame the rules above keep
+	// and drops it by returning false. It sees the untrimmed file path.
+	Filter func(Frame) bool
+	// TrimModule rewrites file paths relative to their module: frames of
+	// the main module render relative to its root directory
+	// ("internal/db/query.go"), and frames of dependencies as their
+	// import path ("github.com/go-chi/chi/v5@v5.0.12/mux.go" from the
+	// module cache, "github.com/acme/lib/x.go" when vendored). Paths whose
+	// module cannot be determined are left unchanged.
+	TrimModule bool
+	// TrimPrefix is removed from the start of file paths that TrimModule
+	// did not rewrite, such as a build directory.
+	TrimPrefix string
+}
+
+// framePolicy holds the process-global FramePolicy; nil selects the
+// built-in rules.
+var framePolicy atomic.Pointer[FramePolicy]
+
+// SetFramePolicy installs the process-global frame policy.
+// SetFramePolicy(nil) restores the built-in rules. The policy is copied,
+// so later changes to p have no effect. An error's trace is rendered once
+// and cached, so set the policy at startup, before errors are logged.
+func SetFramePolicy(p *FramePolicy) {
+	if p == nil {
+		framePolicy.Store(nil)
+		return
+	}
+	c := *p
+	c.Include = append([]string(nil), p.Include...)
+	c.Exclude = append([]string(nil), p.Exclude...)
+	framePolicy.Store(&c)
+}
+
+// drop reports whether f is hidden from rendered traces under p; a nil p
+// applies the built-in rules alone.
+func (p *FramePolicy) drop(f runtime.Frame) bool {
+	if p == nil {
+		return skipFrame(f)
+	}
+	if f.Function == "" || strings.HasPrefix(f.Function, selfPkgPrefix) {
+		return true
+	}
+	if !matchPackage(p.Include, f.Function) &&
+		(matchPackage(p.Exclude, f.Function) || skipFrame(f)) {
+		return true
+	}
+	return p.Filter != nil && !p.Filter(Frame{File: f.File, Line: f.Line, Function: f.Function})
+}
+
+// file returns f's file path as rendered under p.
+func (p *FramePolicy) file(f runtime.Frame) string {
+	if p == nil {
+		return f.File
+	}
+	if p.TrimModule {
+		if rel, ok := moduleRelative(f); ok {
+			return rel
+		}
+	}
+	if p.TrimPrefix != "" {
+		return strings.TrimPrefix(f.File, p.TrimPrefix)
+	}
+	return f.File
+}
+
+// matchPackage reports whether the function name fn belongs to a package
+// under one of prefixes.
+func matchPackage(prefixes []string, fn string) bool {
+	for _, p := range prefixes {
+		if p == "" || !strings.HasPrefix(fn, p) {
+			continue
+		}
+		if len(fn) == len(p) || strings.HasSuffix(p, "/") || strings.HasSuffix(p, ".") {
+			return true
+		}
+		if c := fn[len(p)]; c == '.' || c == '/' {
+			return true
+		}
+	}
+	return false
+}
+
+// funcPackage returns the import path of the package defining the fully
+// qualified function name fn: everything before the first '.' after the
+// last '/'. The linker escapes dots in that last element as "%2e"
+// ("gopkg.in/yaml%2ev3.Unmarshal"), which are restored.
+func funcPackage(fn string) string {
+	slash := strings.LastIndexByte(fn, '/')
+	dot := strings.IndexByte(fn[slash+1:], '.')
+	if dot >= 0 {
+		fn = fn[:slash+1+dot]
+	}
+	return strings.ReplaceAll(fn, "%2e", ".")
+}
+
+// mainModulePath is the module path of the running binary, "" when the
+// build carries no module information.
+var mainModulePath = sync.OnceValue(func() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return bi.Main.Path
+})
+
+// mainModuleRoot caches the main module's root directory once a frame has
+// revealed it.
+var mainModuleRoot atomic.Pointer[string]
+
+// moduleRelative implements FramePolicy.TrimModule for one frame.
+func moduleRelative(f runtime.Frame) (string, bool) {
+	file := f.File
+	mod := mainModulePath()
+	// -trimpath already reports main-module files by import path.
+	if mod != "" && strings.HasPrefix(file, mod+"/") {
+		return file[len(mod)+1:], true
+	}
+	if i := strings.LastIndex(file, "/pkg/mod/"); i >= 0 {
+		return file[i+len("/pkg/mod/"):], true
+	}
+	pkg := funcPackage(f.Function)
+	if pkg == "main" {
+		root := mainModuleRoot.Load()
+		if root == nil {
+			if r := findModuleRoot(path.Dir(file)); r != "" {
+				mainModuleRoot.CompareAndSwap(nil, &r)
+				root = &r
+			}
+		}
+		if root != nil && strings.HasPrefix(file, *root) {
+			return file[len(*root):], true
+		}
+		return "", false
+	}
+	dir := path.Dir(file)
+	if mod != "" {
+		// External test packages are compiled from the directory of the
+		// package they test.
+		if p := strings.TrimSuffix(pkg, "_test"); p == mod || strings.HasPrefix(p, mod+"/") {
+			sub := p[len(mod):]
+			if !strings.HasSuffix(dir, sub) {
+				return "", false
+			}
+			root := dir[:len(dir)-len(sub)] + "/"
+			mainModuleRoot.CompareAndSwap(nil, &root)
+			return file[len(root):], true
+		}
+	}
+	// A vendored package sits in a directory named after its import path.
+	if !hasPathSuffix(dir, pkg) {
+		return "", false
+	}
+	return file[len(dir)-len(pkg):], true
+}
+
+// hasPathSuffix reports whether dir ends with the path elements of pkg.
+func hasPathSuffix(dir, pkg string) bool {
+	return dir == pkg || strings.HasSuffix(dir, "/"+pkg)
+}
+
+// moduleRootLookup remembers the result of findModuleRoot's one search of
+// the file system.
+var moduleRootLookup struct {
+	once sync.Once
+	root string
+}
+
+// findModuleRoot returns the directory holding go.mod at or above dir,
+// with a trailing slash, for package main frames, whose function names do
+// not reveal where the package sits in the module. It searches the file
+// system only once and returns "" when the sources are not present, as on
+// a machine other than the build machine.
+func findModuleRoot(dir string) string {
+	moduleRootLookup.once.Do(func() {
+		for d := dir; d != "/" && d != "."; d = path.Dir(d) {
+			if _, err := os.Stat(d + "/go.mod"); err == nil {
+				moduleRootLookup.root = d + "/"
+				return
+			}
+		}
+	})
+	return moduleRootLookup.root
+}
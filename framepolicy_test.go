@@ -0,0 +1,124 @@
+package aerr_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// frameFuncs returns the function names of the error's frames.
+func frameFuncs(t *testing.T, err error) []string {
+	t.Helper()
+	e, ok := aerr.AsAerr(err)
+	if !ok {
+		t.Fatal("expected aerr")
+	}
+	var out []string
+	for _, f := range e.Frames() {
+		out = append(out, f.Function)
+	}
+	return out
+}
+
+func hasFunc(funcs []string, prefix string) bool {
+	for _, fn := range funcs {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFramePolicyExclude(t *testing.T) {
+	aerr.SetFramePolicy(&aerr.FramePolicy{Exclude: []string{"github.com/tafaquh/aerr_test"}})
+	defer aerr.SetFramePolicy(nil)
+
+	err := aerr.StackTrace().Err(nil)
+	if funcs := frameFuncs(t, err); hasFunc(funcs, "github.com/tafaquh/aerr_test.") {
+		t.Errorf("excluded package still in Frames(): %v", funcs)
+	}
+	if s := fmt.Sprintf("%+v", err); strings.Contains(s, "TestFramePolicyExclude") {
+		t.Errorf("excluded frame still rendered by %%+v:\n%s", s)
+	}
+}
+
+func TestFramePolicyInclude(t *testing.T) {
+	err := aerr.StackTrace().Err(nil)
+	if hasFunc(frameFuncs(t, err), "testing.") {
+		t.Fatal("built-in rules should hide the testing package")
+	}
+
+	aerr.SetFramePolicy(&aerr.FramePolicy{Include: []string{"testing"}})
+	defer aerr.SetFramePolicy(nil)
+	err = aerr.StackTrace().Err(nil)
+	funcs := frameFuncs(t, err)
+	if !hasFunc(funcs, "testing.tRunner") {
+		t.Errorf("included stdlib package missing from Frames(): %v", funcs)
+	}
+	if hasFunc(funcs, "runtime.") {
+		t.Errorf("Include re-admitted packages it does not name: %v", funcs)
+	}
+	if hasFunc(funcs, "github.com/tafaquh/aerr.") {
+		t.Errorf("aerr internals must stay hidden: %v", funcs)
+	}
+}
+
+func TestFramePolicyFilter(t *testing.T) {
+	var seen []aerr.Frame
+	aerr.SetFramePolicy(&aerr.FramePolicy{Filter: func(f aerr.Frame) bool {
+		seen = append(seen, f)
+		return !strings.HasSuffix(f.Function, "TestFramePolicyFilter")
+	}})
+	defer aerr.SetFramePolicy(nil)
+
+	err := aerr.StackTrace().Err(nil)
+	e, _ := aerr.AsAerr(err)
+	if traces := e.Traces(); traces != nil {
+		t.Errorf("filtered frame still in Traces(): %v", traces)
+	}
+	if len(seen) == 0 || !strings.HasSuffix(seen[0].File, "/framepolicy_test.go") {
+		t.Errorf("Filter saw %+v, want the untrimmed user frame", seen)
+	}
+}
+
+func TestFramePolicyTrim(t *testing.T) {
+	// Traces and Frames render lazily, under the policy current at the time.
+	aerr.SetFramePolicy(&aerr.FramePolicy{TrimModule: true})
+	e, _ := aerr.AsAerr(aerr.StackTrace().Err(nil))
+	frames := e.Frames()
+	traces := e.Traces()
+	aerr.SetFramePolicy(nil)
+
+	if len(frames) == 0 {
+		t.Fatal("expected frames")
+	}
+	if frames[0].File != "framepolicy_test.go" {
+		t.Errorf("TrimModule: File = %q, want module-relative %q", frames[0].File, "framepolicy_test.go")
+	}
+	if !strings.HasPrefix(traces[0], "framepolicy_test.go:") {
+		t.Errorf("TrimModule: Traces()[0] = %q", traces[0])
+	}
+
+	full, _ := aerr.AsAerr(aerr.StackTrace().Err(nil))
+	file := full.Frames()[0].File
+	dir := file[:strings.LastIndexByte(file, '/')+1]
+	aerr.SetFramePolicy(&aerr.FramePolicy{TrimPrefix: dir})
+	defer aerr.SetFramePolicy(nil)
+	trimmed, _ := aerr.AsAerr(aerr.StackTrace().Err(nil))
+	if got := trimmed.Frames()[0].File; got != "framepolicy_test.go" {
+		t.Errorf("TrimPrefix: File = %q, want %q", got, "framepolicy_test.go")
+	}
+}
+
+func TestSetFramePolicyCopies(t *testing.T) {
+	p := &aerr.FramePolicy{Exclude: []string{"github.com/tafaquh/aerr_test"}}
+	aerr.SetFramePolicy(p)
+	defer aerr.SetFramePolicy(nil)
+	p.Exclude[0] = "unrelated"
+
+	if funcs := frameFuncs(t, aerr.StackTrace().Err(nil)); hasFunc(funcs, "github.com/tafaquh/aerr_test.") {
+		t.Errorf("mutating the policy after SetFramePolicy changed it: %v", funcs)
+	}
+}
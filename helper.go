@@ -0,0 +1,116 @@
+package aerr
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// helpers is the process-global set of helper functions whose frames are
+// stripped from the start of captured stacks. pcs remembers the Helper
+// call sites already resolved, so repeated calls skip symbolization;
+// names holds the fully qualified function names that are matched.
+var helpers struct {
+	pcs   sync.Map // uintptr -> struct{}
+	names sync.Map // string -> struct{}
+	any   atomic.Bool
+}
+
+// Helper marks the calling function as an error helper, in the manner of
+// testing.T.Helper: when a stack is captured, frames of marked functions
+// at the top of the stack are skipped, so the trace starts at the helper's
+// caller rather than inside the helper.
+//
+//	func dbErr(err error) error {
+//		aerr.Helper()
+//		return aerr.Code("DB_ERROR").StackTrace().Wrap(err)
+//	}
+//
+// Marking is permanent and takes effect from the first call; later calls
+// from the same site are cheap. Helpers may call other helpers.
+func Helper() {
+	var pc [1]uintptr
+	if runtime.Callers(2, pc[:]) == 0 {
+		return
+	}
+	if _, ok := helpers.pcs.Load(pc[0]); ok {
+		return
+	}
+	f, _ := runtime.CallersFrames(pc[:]).Next()
+	if f.Function != "" {
+		helpers.names.Store(f.Function, struct{}{})
+		helpers.any.Store(true)
+	}
+	helpers.pcs.Store(pc[0], struct{}{})
+}
+
+// RegisterHelpers marks functions as error helpers by fully qualified
+// name ("github.com/acme/app/store.dbErr", "github.com/acme/app.(*Repo).fail"),
+// with the same effect as calling [Helper] inside each of them. It suits
+// helpers that cannot be edited, or marking at startup before any error
+// is issued.
+func RegisterHelpers(names ...string) {
+	for _, name := range names {
+		if name != "" {
+			helpers.names.Store(name, struct{}{})
+			helpers.any.Store(true)
+		}
+	}
+}
+
+// isHelper reports whether fn is a marked helper function.
+func isHelper(fn string) bool {
+	if !helpers.any.Load() {
+		return false
+	}
+	_, ok := helpers.names.Load(fn)
+	return ok
+}
+
+// trimHelpers drops the leading PCs of pcs that belong to helper
+// functions.
+func trimHelpers(pcs []uintptr) []uintptr {
+	if !helpers.any.Load() {
+		return pcs
+	}
+	n := leadingHelpers(pcs)
+	if n == len(pcs) {
+		return nil
+	}
+	return pcs[n:]
+}
+
+// leadingHelpers returns how many frames at the start of pcs belong to
+// helper functions. It walks the frames of the whole slice, as the
+// runtime requires for inlined calls to resolve correctly; since
+// runtime.Callers reports one PC per logical frame, inlined frames
+// included, the count also indexes pcs.
+func leadingHelpers(pcs []uintptr) int {
+	if len(pcs) == 0 {
+		return 0
+	}
+	frames := runtime.CallersFrames(pcs)
+	n := 0
+	for {
+		f, more := frames.Next()
+		if !isHelper(f.Function) {
+			return n
+		}
+		n++
+		if !more {
+			return n
+		}
+	}
+}
+
+// StackTraceSkip enables stack capture like StackTrace, starting the trace
+// n frames above the call site, for wrappers that always sit between the
+// origin and aerr. n <= 0 starts at the call site. See also [Helper].
+func (b *Builder) StackTraceSkip(n int) *Builder {
+	if n < 0 {
+		n = 0
+	}
+	b.captureStack = true
+	b.skip = n
+	return b
+}
@@ -0,0 +1,111 @@
+package aerr_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+func markedHelper(err error) error {
+	aerr.Helper()
+	return aerr.Code("DB").StackTrace().Wrap(err)
+}
+
+func outerHelper(err error) error {
+	aerr.Helper()
+	return markedHelper(err)
+}
+
+func registeredHelper(err error) error {
+	return aerr.Code("DB").StackTrace().Wrap(err)
+}
+
+func unmarkedHelper(err error) error {
+	return aerr.Code("DB").StackTrace().Wrap(err)
+}
+
+func skippingHelper(err error) error {
+	return aerr.Code("DB").StackTraceSkip(1).Wrap(err)
+}
+
+// topFrame returns the first rendered frame of err's trace.
+func topFrame(t *testing.T, err error) string {
+	t.Helper()
+	e, ok := aerr.AsAerr(err)
+	if !ok || len(e.Traces()) == 0 {
+		t.Fatal("expected a captured trace")
+	}
+	return e.Traces()[0]
+}
+
+func TestHelperSkipsMarkedFrames(t *testing.T) {
+	cause := errors.New("io")
+	if top := topFrame(t, unmarkedHelper(cause)); !strings.Contains(top, "unmarkedHelper") {
+		t.Fatalf("unmarked helper: top frame %q, want the helper", top)
+	}
+	for name, err := range map[string]error{
+		"Helper":         markedHelper(cause),
+		"nested Helper":  outerHelper(cause),
+		"StackTraceSkip": skippingHelper(cause),
+	} {
+		top := topFrame(t, err)
+		if !strings.Contains(top, "TestHelperSkipsMarkedFrames") {
+			t.Errorf("%s: top frame %q, want the helper's caller", name, top)
+		}
+	}
+}
+
+func TestRegisterHelpers(t *testing.T) {
+	aerr.RegisterHelpers("github.com/tafaquh/aerr_test.registeredHelper", "")
+	top := topFrame(t, registeredHelper(errors.New("io")))
+	if !strings.Contains(top, "TestRegisterHelpers") {
+		t.Errorf("top frame %q, want the registered helper's caller", top)
+	}
+}
+
+func TestHelperPerSiteSampling(t *testing.T) {
+	aerr.SetCapturePolicy(&aerr.CapturePolicy{
+		Default: aerr.CaptureRule{Mode: aerr.CaptureSampled, Every: 100, PerSite: true},
+	})
+	defer aerr.SetCapturePolicy(nil)
+
+	sampleHelper := func() error {
+		aerr.Helper()
+		return aerr.Code("SAMPLED").Err(nil)
+	}
+	first := sampleHelper()
+	second := sampleHelper()
+	if !hasStack(first) || !hasStack(second) {
+		t.Error("calls to a helper from different sites shared one sample count")
+	}
+}
+
+func TestStackTraceSkipNegative(t *testing.T) {
+	top := topFrame(t, aerr.Code("X").StackTraceSkip(-3).Err(nil))
+	if !strings.Contains(top, "TestStackTraceSkipNegative") {
+		t.Errorf("StackTraceSkip(-3): top frame %q, want the call site", top)
+	}
+}
+
+// inlinedHelper is small enough for the compiler to inline into its
+// caller, so its frame and the caller's share one physical frame.
+func inlinedHelper(b *aerr.Builder) error {
+	return b.Err(nil)
+}
+
+func TestRegisterHelpersInlined(t *testing.T) {
+	aerr.RegisterHelpers("github.com/tafaquh/aerr_test.inlinedHelper")
+	top := topFrame(t, inlinedHelper(aerr.Code("X").StackTrace()))
+	if !strings.Contains(top, "TestRegisterHelpersInlined") {
+		t.Errorf("top frame %q, want the inlined helper's caller", top)
+	}
+
+	aerr.SetReturnTrace(true)
+	defer aerr.SetReturnTrace(false)
+	e, _ := aerr.AsAerr(inlinedHelper(aerr.Code("X")))
+	if sites := e.WrappedAt(); len(sites) != 1 || !strings.Contains(sites[0], "TestRegisterHelpersInlined") {
+		t.Errorf("WrappedAt() = %q, want the inlined helper's caller", sites)
+	}
+}
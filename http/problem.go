@@ -0,0 +1,280 @@
+// Package aerrhttp renders aerr errors as RFC 7807 problem details
+// (application/problem+json) and adapts error-returning handlers to
+// net/http.
+//
+// Map codes to HTTP statuses once at startup, then write problems from
+// any handler or let Handler do it:
+//
+//	func main() {
+//		aerrhttp.RegisterStatus("USER_NOT_FOUND", http.StatusNotFound)
+//		http.Handle("/users/", aerrhttp.Handler(getUser))
+//	}
+//
+//	func getUser(w http.ResponseWriter, r *http.Request) error {
+//		return aerr.Code("USER_NOT_FOUND").Message("user not found").Err(nil)
+//	}
+//
+// The rendered problem never includes a stack trace, the message of a
+// server error, or an attribute the caller did not allow with PublicAttrs
+// or AttrFilter, unless the Debug option is set. Attributes wrapped with
+// aerr.Redact render as aerr.RedactedText in either case.
+package aerrhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tafaquh/aerr"
+)
+
+// ContentType is the media type WriteProblem responds with.
+const ContentType = "application/problem+json"
+
+// statuses is the process-global code→status registry. A published map is
+// never mutated: RegisterStatus swaps in a fresh copy under statusMu, so
+// readers need only an atomic load.
+var (
+	statusMu sync.Mutex
+	statuses atomic.Pointer[map[string]int]
+)
+
+// RegisterStatus maps an aerr code to the HTTP status WriteProblem and
+// StatusOf report for it. Registering a code again replaces its status.
+// Safe for concurrent use, though intended as startup configuration.
+func RegisterStatus(code string, status int) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	next := make(map[string]int)
+	if cur := statuses.Load(); cur != nil {
+		for k, v := range *cur {
+			next[k] = v
+		}
+	}
+	next[code] = status
+	statuses.Store(&next)
+}
+
+// StatusOf returns the HTTP status registered for err: the status of the
+// outermost aerr layer whose code is registered, so an unregistered outer
+// code does not hide a registered inner one. An aerr.Coder's code counts
+// as the code of the aerr layer wrapping it (see aerr.Layer), and as the
+// only layer of a chain with no *aerr.Error (see aerr.From). It returns
+// 500 when no layer matches.
+func StatusOf(err error) int {
+	if m := statuses.Load(); m != nil {
+		layers := aerr.Layers(err)
+		if len(layers) == 0 {
+			// A chain without an *aerr.Error can still carry a Coder's
+			// code. Swapping in From's result only then keeps every
+			// branch of an errors.Join tree in the walk.
+			if e, ok := aerr.From(err); ok {
+				layers = aerr.Layers(e)
+			}
+		}
+		for _, l := range layers {
+			if status, ok := (*m)[l.Err.Code()]; ok {
+				return status
+			}
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// Option configures WriteProblem and Handler.
+type Option func(*config)
+
+type config struct {
+	debug    bool
+	typeBase string
+	allow    func(key string, value any) bool
+}
+
+// Debug includes the stack trace, the message of server errors, and every
+// attribute in the problem. Never enable it for responses that reach
+// untrusted clients.
+func Debug() Option {
+	return func(c *config) {
+		c.debug = true
+	}
+}
+
+// TypeBase sets the problem "type" to base followed by the lower-cased
+// code (e.g. "https://errors.example.com/user_not_found"). Without it, or
+// for errors without a code, the type is "about:blank".
+func TypeBase(base string) Option {
+	return func(c *config) {
+		c.typeBase = base
+	}
+}
+
+// PublicAttrs includes the attributes with the given keys in the problem.
+func PublicAttrs(keys ...string) Option {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return AttrFilter(func(key string, _ any) bool {
+		_, ok := set[key]
+		return ok
+	})
+}
+
+// AttrFilter includes the attributes for which fn returns true in the
+// problem. It replaces any earlier PublicAttrs or AttrFilter option.
+func AttrFilter(fn func(key string, value any) bool) Option {
+	return func(c *config) {
+		c.allow = fn
+	}
+}
+
+// problem is the RFC 7807 document, in member order. code, attributes,
+// and stacktrace are extension members.
+type problem struct {
+	Type       string          `json:"type"`
+	Title      string          `json:"title"`
+	Status     int             `json:"status"`
+	Detail     string          `json:"detail,omitempty"`
+	Instance   string          `json:"instance,omitempty"`
+	Code       string          `json:"code,omitempty"`
+	Attributes json.RawMessage `json:"attributes,omitempty"`
+	Stacktrace []string        `json:"stacktrace,omitempty"`
+}
+
+// WriteProblem writes err to w as an application/problem+json response
+// with the status from StatusOf. The detail member carries err's message
+// for client errors (4xx) only; server errors omit it unless Debug is set,
+// since their messages tend to describe internals. r supplies the
+// instance member and may be nil.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error, opts ...Option) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	status := StatusOf(err)
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	if err != nil && (status < 500 || c.debug) {
+		p.Detail = errMessage(err)
+	}
+	if r != nil && r.URL != nil {
+		p.Instance = r.URL.RequestURI()
+	}
+	if e, ok := aerr.From(err); ok {
+		p.Code = e.Code()
+		if p.Code != "" && c.typeBase != "" {
+			p.Type = c.typeBase + strings.ToLower(p.Code)
+		}
+		p.Attributes = attributesJSON(e, &c)
+		if c.debug {
+			p.Stacktrace = e.Traces()
+		}
+	}
+	body, jerr := json.Marshal(p)
+	if jerr != nil {
+		// Only the attributes can fail to encode, and attributesJSON
+		// never produces invalid JSON; keep the response well-formed
+		// regardless.
+		p.Attributes = nil
+		body, _ = json.Marshal(p)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	// The status line is already sent; a failed body write has no one
+	// left to report to.
+	_, _ = w.Write(body)
+}
+
+// HandlerFunc is an http.HandlerFunc that returns its error instead of
+// writing it.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handler adapts fn to an http.Handler that writes any returned error with
+// WriteProblem and opts. fn must not have written a response when it
+// returns a non-nil error.
+func Handler(fn HandlerFunc, opts ...Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			WriteProblem(w, r, err, opts...)
+		}
+	})
+}
+
+// attributesJSON encodes the attributes c allows as a JSON object in
+// insertion order, or returns nil when none are allowed. Debug allows all.
+func attributesJSON(e *aerr.Error, c *config) json.RawMessage {
+	if e.NumAttrs() == 0 || (c.allow == nil && !c.debug) {
+		return nil
+	}
+	buf := []byte{'{'}
+	e.RangeAttrs(func(k string, v any) bool {
+		if !c.debug && !c.allow(k, v) {
+			return true
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		key, _ := json.Marshal(k)
+		buf = append(buf, key...)
+		buf = append(buf, ':')
+		buf = append(buf, valueJSON(v)...)
+		return true
+	})
+	if len(buf) == 1 {
+		return nil
+	}
+	return append(buf, '}')
+}
+
+// valueJSON encodes one attribute value the way aerr's own MarshalJSON
+// does: errors (that are not json.Marshalers) as their message, values
+// encoding/json rejects as their fmt form, and a panicking MarshalJSON as
+// a "<panic: ...>" placeholder. aerr.Redacted marshals itself to the
+// placeholder text.
+func valueJSON(v any) (out []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, _ = json.Marshal(fmt.Sprintf("<panic: %v>", r))
+		}
+	}()
+	if _, ok := v.(json.Marshaler); !ok {
+		if er, ok := v.(error); ok {
+			v = errMessage(er)
+		}
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		out, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return out
+}
+
+// errMessage returns err's message, tolerating typed-nil errors and
+// Error implementations that panic; rendering a response must never crash
+// the handler. Nil-ish values render as "<nil>".
+func errMessage(err error) (msg string) {
+	if err == nil {
+		return "<nil>"
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("<panic: %v>", r)
+		}
+	}()
+	rv := reflect.ValueOf(err)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if rv.IsNil() {
+			return "<nil>"
+		}
+	}
+	return err.Error()
+}
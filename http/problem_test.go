@@ -0,0 +1,184 @@
+package aerrhttp_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+	aerrhttp "github.com/tafaquh/aerr/http"
+)
+
+func init() {
+	aerrhttp.RegisterStatus("HTTP_NOT_FOUND", http.StatusNotFound)
+	aerrhttp.RegisterStatus("HTTP_CONFLICT", http.StatusConflict)
+}
+
+// serve runs err through WriteProblem and decodes the response body.
+func serve(t *testing.T, err error, opts ...aerrhttp.Option) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users/42?x=1", nil)
+	aerrhttp.WriteProblem(rec, req, err, opts...)
+
+	var body map[string]any
+	if jerr := json.Unmarshal(rec.Body.Bytes(), &body); jerr != nil {
+		t.Fatalf("response is not valid JSON: %v\n%s", jerr, rec.Body.String())
+	}
+	return rec, body
+}
+
+func TestWriteProblemClientError(t *testing.T) {
+	err := aerr.Code("HTTP_NOT_FOUND").
+		Message("user not found").
+		StackTrace().
+		With("user_id", "42").
+		With("query", "SELECT * FROM users").
+		Err(nil)
+
+	rec, body := serve(t, err, aerrhttp.PublicAttrs("user_id"), aerrhttp.TypeBase("https://errors.example.com/"))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != aerrhttp.ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, aerrhttp.ContentType)
+	}
+	want := map[string]any{
+		"type":       "https://errors.example.com/http_not_found",
+		"title":      "Not Found",
+		"status":     float64(404),
+		"detail":     "user not found",
+		"instance":   "/users/42?x=1",
+		"code":       "HTTP_NOT_FOUND",
+		"attributes": map[string]any{"user_id": "42"},
+	}
+	if fmt.Sprint(body) != fmt.Sprint(want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+}
+
+func TestWriteProblemServerErrorHidesInternals(t *testing.T) {
+	err := aerr.Code("DB_DOWN").
+		Message("dial 10.0.0.5:5432 failed").
+		StackTrace().
+		With("dsn", "postgres://...").
+		Err(nil)
+
+	rec, body := serve(t, err)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	for _, key := range []string{"detail", "attributes", "stacktrace"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("server error leaked %q: %v", key, body)
+		}
+	}
+	if body["type"] != "about:blank" || body["code"] != "DB_DOWN" {
+		t.Errorf("type/code = %v/%v, want about:blank/DB_DOWN", body["type"], body["code"])
+	}
+}
+
+func TestWriteProblemDebug(t *testing.T) {
+	err := aerr.Code("DB_DOWN").
+		Message("dial failed").
+		StackTrace().
+		With("password", aerr.Redact("hunter2")).
+		With("cause", errors.New("refused")).
+		Err(nil)
+
+	rec, body := serve(t, err, aerrhttp.Debug())
+	if body["detail"] != "dial failed" {
+		t.Errorf("debug detail = %v, want message", body["detail"])
+	}
+	attrs, _ := body["attributes"].(map[string]any)
+	if attrs["password"] != aerr.RedactedText || attrs["cause"] != "refused" {
+		t.Errorf("debug attributes = %v, want redacted password and cause message", attrs)
+	}
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Error("redacted value leaked into the response")
+	}
+	stack, _ := body["stacktrace"].([]any)
+	if len(stack) == 0 || !strings.Contains(fmt.Sprint(stack[0]), "TestWriteProblemDebug") {
+		t.Errorf("debug stacktrace = %v, want the origin frame", stack)
+	}
+}
+
+// notFoundError contributes its code through aerr.Coder.
+type notFoundError struct{}
+
+func (notFoundError) Error() string     { return "no such user" }
+func (notFoundError) ErrorCode() string { return "HTTP_NOT_FOUND" }
+
+// causer links to its cause only through Cause, like pkg/errors wrappers.
+type causer struct{ cause error }
+
+func (c causer) Error() string { return "legacy: " + c.cause.Error() }
+func (c causer) Cause() error  { return c.cause }
+
+func TestStatusOfWalksLayers(t *testing.T) {
+	inner := aerr.Code("HTTP_CONFLICT").ErrMsg("dup")
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"registered outer", aerr.Code("HTTP_NOT_FOUND").Wrap(inner), 404},
+		{"unregistered outer", aerr.Code("HANDLER").Wrap(fmt.Errorf("x: %w", inner)), 409},
+		{"foreign coder", fmt.Errorf("x: %w", notFoundError{}), 404},
+		{"behind Cause", aerr.Code("HANDLER").Wrap(causer{inner}), 409},
+		{"join", errors.Join(aerr.Code("OTHER").Err(nil), aerr.Code("HTTP_NOT_FOUND").Err(nil)), 404},
+		{"join with coder", errors.Join(notFoundError{}, inner), 409},
+		{"no aerr", errors.New("plain"), 500},
+		{"nil", nil, 500},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := aerrhttp.StatusOf(tc.err); got != tc.want {
+				t.Errorf("StatusOf = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := aerrhttp.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		if r.URL.Path == "/ok" {
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+		return aerr.Code("HTTP_NOT_FOUND").Message("missing").Err(nil)
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Errorf("ok path: status %d body %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `"code":"HTTP_NOT_FOUND"`) {
+		t.Errorf("error path: status %d body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestWriteProblemPlainErrorAndNilRequest(t *testing.T) {
+	rec := httptest.NewRecorder()
+	aerrhttp.WriteProblem(rec, nil, errors.New("boom"))
+	want := `{"type":"about:blank","title":"Internal Server Error","status":500}`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+func TestWriteProblemForeignCoder(t *testing.T) {
+	rec, body := serve(t, notFoundError{})
+	if rec.Code != http.StatusNotFound || body["code"] != "HTTP_NOT_FOUND" || body["detail"] != "no such user" {
+		t.Errorf("status %d, body %v", rec.Code, body)
+	}
+}
@@ -0,0 +1,153 @@
+// Package aerrtest holds fixtures shared by the tests of the logging
+// adapters, which render an *aerr.Error to the same JSON object through
+// different loggers.
+package aerrtest
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// DomainError contributes a code and attributes without aerr.
+type DomainError struct{}
+
+func (DomainError) Error() string           { return "quota exceeded" }
+func (DomainError) ErrorCode() string       { return "QUOTA" }
+func (DomainError) ErrorAttrs() []slog.Attr { return []slog.Attr{slog.Int("limit", 10)} }
+
+// RenderCase is one render option an adapter must honor. Err builds the
+// error, switching on any setting it needs only while building. Setup,
+// when set, switches on a setting that applies while logging and returns
+// the function that restores it. Check inspects the logged error object.
+type RenderCase struct {
+	Name  string
+	Err   func() error
+	Setup func() (restore func())
+	Check func(t testing.TB, obj map[string]any, err error)
+}
+
+// RenderCases returns the render options every adapter shares with
+// MarshalJSON.
+func RenderCases() []RenderCase {
+	return []RenderCase{
+		{
+			Name: "fingerprint",
+			Err:  func() error { return aerr.Code("FP").Message("m").Err(nil) },
+			Setup: func() func() {
+				aerr.SetFingerprintOptions(aerr.FingerprintOptions{Emit: true})
+				return func() { aerr.SetFingerprintOptions(aerr.FingerprintOptions{}) }
+			},
+			Check: func(t testing.TB, obj map[string]any, err error) {
+				e, _ := aerr.AsAerr(err)
+				if obj["fingerprint"] != e.Fingerprint() {
+					t.Errorf("fingerprint = %v, want %s", obj["fingerprint"], e.Fingerprint())
+				}
+			},
+		},
+		{
+			Name: "wrapped_at",
+			Err: func() error {
+				aerr.SetReturnTrace(true)
+				defer aerr.SetReturnTrace(false)
+				return aerr.Message("outer").Wrap(aerr.Code("RT").Message("m").Err(nil))
+			},
+			Check: func(t testing.TB, obj map[string]any, err error) {
+				e, _ := aerr.AsAerr(err)
+				sites, _ := obj["wrapped_at"].([]any)
+				if len(sites) != 2 || sites[1] != e.WrappedAt()[1] {
+					t.Errorf("wrapped_at = %v, want %q", obj["wrapped_at"], e.WrappedAt())
+				}
+			},
+		},
+		{
+			Name: "layer_stacks",
+			Err: func() error {
+				aerr.SetStackMode(aerr.StackPerLayer)
+				defer aerr.SetStackMode(aerr.StackDeepest)
+				inner := aerr.Code("INNER").StackTrace().Err(nil)
+				return aerr.Code("OUTER").Message("outer").StackTrace().Wrap(inner)
+			},
+			Check: func(t testing.TB, obj map[string]any, err error) {
+				e, _ := aerr.AsAerr(err)
+				secs, _ := obj["layer_stacks"].([]any)
+				if len(secs) != 1 {
+					t.Fatalf("layer_stacks = %v, want one section", obj["layer_stacks"])
+				}
+				sec, _ := secs[0].(map[string]any)
+				frames, _ := sec["stacktrace"].([]any)
+				if sec["code"] != "OUTER" || sec["message"] != "outer" || len(frames) == 0 || frames[0] != e.StackSections()[1].Traces[0] {
+					t.Errorf("layer_stacks[0] = %v", sec)
+				}
+			},
+		},
+		{
+			Name: "stack_raw",
+			Err:  func() error { return aerr.Code("RAW").StackTrace().Err(nil) },
+			Setup: func() func() {
+				aerr.SetRawStacks(true)
+				return func() { aerr.SetRawStacks(false) }
+			},
+			Check: func(t testing.TB, obj map[string]any, err error) {
+				e, _ := aerr.AsAerr(err)
+				raw, _ := e.RawStack()
+				if _, ok := obj["stacktrace"]; ok {
+					t.Errorf("stacktrace emitted with raw stacks on: %v", obj)
+				}
+				got, _ := obj["stack_raw"].(map[string]any)
+				pcs, _ := got["pcs"].([]any)
+				if len(pcs) != len(raw.PCs) || pcs[0] != raw.HexPCs()[0] || got["build_id"] != raw.BuildID {
+					t.Errorf("stack_raw = %v, want %+v", got, raw)
+				}
+			},
+		},
+		{
+			Name: "diagnostics",
+			Err:  func() error { return aerr.Code("DIAG").With("k", "v").Diagnostics().Err(nil) },
+			Check: func(t testing.TB, obj map[string]any, err error) {
+				e, _ := aerr.AsAerr(err)
+				diag, _ := obj["diagnostics"].(map[string]any)
+				if diag["gomaxprocs"] != float64(e.Diagnostics().GOMAXPROCS) || diag["go_version"] != e.Diagnostics().GoVersion {
+					t.Errorf("diagnostics = %v, want %+v", obj["diagnostics"], e.Diagnostics())
+				}
+				if attrs, _ := obj["attributes"].(map[string]any); len(attrs) != 1 {
+					t.Errorf("attributes = %v, want only k", obj["attributes"])
+				}
+			},
+		},
+		{
+			Name: "foreign contributions",
+			Err:  func() error { return DomainError{} },
+			Check: func(t testing.TB, obj map[string]any, _ error) {
+				attrs, _ := obj["attributes"].(map[string]any)
+				if obj["code"] != "QUOTA" || obj["message"] != "quota exceeded" || attrs["limit"] != float64(10) {
+					t.Errorf("error = %v", obj)
+				}
+			},
+		},
+		{
+			Name: "schema",
+			Err: func() error {
+				return aerr.Code("NOT_FOUND").Message("no user").With("user_id", 7).StackTrace().Err(nil)
+			},
+			Setup: func() func() {
+				aerr.SetSchema(aerr.ECSSchema())
+				return func() { aerr.SetSchema(aerr.DefaultSchema()) }
+			},
+			Check: func(t testing.TB, obj map[string]any, err error) {
+				e, _ := aerr.AsAerr(err)
+				if obj["error.code"] != "NOT_FOUND" || obj["error.message"] != "no user" {
+					t.Errorf("error = %v", obj)
+				}
+				if obj["error.stack_trace"] != strings.Join(e.Traces(), "\n") {
+					t.Errorf("error.stack_trace = %v, want the joined trace", obj["error.stack_trace"])
+				}
+				if _, ok := obj["code"]; ok {
+					t.Errorf("default code key emitted: %v", obj)
+				}
+			},
+		},
+	}
+}
@@ -0,0 +1,104 @@
+// Package buildid reads the Go build ID of an executable, shared by aerr
+// (which records its own) and cmd/aerr-symbolize (which checks a binary
+// against a recorded one).
+package buildid
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"io"
+	"os"
+	"runtime/debug"
+)
+
+// RevisionPrefix prefixes the identifier used in place of a build ID when
+// only the VCS revision is known.
+const RevisionPrefix = "vcs.revision:"
+
+// prefix marks the build ID that non-ELF Go binaries carry near the start
+// of their text.
+const prefix = "\xff Go build ID: \""
+
+// Read returns the Go build ID of the executable at path, as
+// `go tool buildid` prints it, or "" when it cannot be found. ELF binaries
+// carry it in a "Go" note; other formats near the start of the file.
+func Read(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	if ef, err := elf.NewFile(f); err == nil {
+		return fromELF(ef)
+	}
+	head := make([]byte, 32<<10)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	i := bytes.Index(head, []byte(prefix))
+	if i < 0 {
+		return ""
+	}
+	rest := head[i+len(prefix):]
+	end := bytes.IndexByte(rest, '"')
+	if end < 0 {
+		return ""
+	}
+	return string(rest[:end])
+}
+
+// Revision returns RevisionPrefix followed by the VCS revision stamped
+// into the executable at path, or "" when it has none.
+func Revision(path string) string {
+	bi, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return revision(bi.Settings)
+}
+
+// Self returns the identifier of the running executable: its build ID
+// when the file can be read, else its revision, else "".
+func Self() string {
+	if exe, err := os.Executable(); err == nil {
+		if id := Read(exe); id != "" {
+			return id
+		}
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		return revision(bi.Settings)
+	}
+	return ""
+}
+
+// revision extracts the identifier from build settings.
+func revision(settings []debug.BuildSetting) string {
+	for _, s := range settings {
+		if s.Key == "vcs.revision" && s.Value != "" {
+			return RevisionPrefix + s.Value
+		}
+	}
+	return ""
+}
+
+// fromELF reads the Go build ID note (name "Go", NUL-padded to 4 bytes by
+// the Go linker; type 4) of an ELF file.
+func fromELF(f *elf.File) string {
+	s := f.Section(".note.go.buildid")
+	if s == nil {
+		return ""
+	}
+	data, err := s.Data()
+	if err != nil || len(data) < 16 {
+		return ""
+	}
+	order := f.ByteOrder
+	namesz := order.Uint32(data[0:4])
+	descsz := order.Uint32(data[4:8])
+	typ := order.Uint32(data[8:12])
+	off := 12 + (namesz+3)&^3
+	if (namesz != 3 && namesz != 4) || typ != 4 || string(data[12:14]) != "Go" || uint64(off)+uint64(descsz) > uint64(len(data)) {
+		return ""
+	}
+	return string(data[off : off+descsz])
+}
@@ -0,0 +1,46 @@
+// Package funcname classifies stack frames by their fully qualified
+// function name, shared by aerr (which falls back to it when the standard
+// library's location is unknown) and cmd/aerr-symbolize (which never
+// knows the capturing host's GOROOT).
+package funcname
+
+import "strings"
+
+// SelfPrefix prefixes the function names of aerr's own frames (e.g.
+// "github.com/tafaquh/aerr.(*Builder).Err"). The trailing dot keeps
+// subpackages and external test packages out of the match.
+const SelfPrefix = "github.com/tafaquh/aerr."
+
+// Hidden reports whether frames of the named function are hidden from
+// rendered traces when classified by name alone: aerr's own and the
+// standard library's. Package main is always user code.
+func Hidden(name string) bool {
+	if strings.HasPrefix(name, SelfPrefix) {
+		return true
+	}
+	if strings.HasPrefix(name, "main.") {
+		return false
+	}
+	return IsStdlib(name)
+}
+
+// IsStdlib reports whether the named function belongs to the standard
+// library. A function name has the form "<import-path>.<func>", where the
+// import path may contain slashes and the func part may contain dots
+// (methods read "(*Type).Method"). The first path segment is the text up
+// to the first '/', or up to the first '.' when there is no slash. A
+// stdlib import path's first segment carries no dot ("runtime", "net",
+// "encoding" for net/http and encoding/json), whereas a module path opens
+// with a domain segment like "github.com".
+//
+// This heuristic cannot tell a locally-developed module whose path is a
+// single dotless word (e.g. `module myapp`) from stdlib, so such a
+// module's frames are mis-classified; give the module a dotted or
+// multi-segment path to keep them. Callers handle package main first.
+func IsStdlib(name string) bool {
+	seg, _, hasSlash := strings.Cut(name, "/")
+	if !hasSlash {
+		seg, _, _ = strings.Cut(name, ".")
+	}
+	return !strings.Contains(seg, ".")
+}
@@ -1,16 +1,32 @@
 package aerr
 
 import (
+	"math"
 	"reflect"
 	"runtime"
 	"testing"
+	"time"
 )
 
 // TestCaptureStackDeepSkip drives captureStack's empty-result branch: a skip
 // larger than the live stack makes runtime.Callers return zero frames.
 func TestCaptureStackDeepSkip(t *testing.T) {
-	if got := captureStack(1000); got != nil {
-		t.Errorf("captureStack(hugeSkip) = %v, want nil", got)
+	if got, n := captureStack(1000, DefaultStackDepth); got != nil || n != 0 {
+		t.Errorf("captureStack(hugeSkip) = %v, %d, want nil, 0", got, n)
+	}
+}
+
+func TestParseTruncationMarker(t *testing.T) {
+	for _, n := range []int{1, 17} {
+		got, ok := parseTruncationMarker(truncationMarker(n))
+		if !ok || got != n {
+			t.Errorf("parseTruncationMarker(%q) = %d, %v", truncationMarker(n), got, ok)
+		}
+	}
+	for _, s := range []string{"", "... x more frames", "... 0 more frames", "... 3 frames", "/src/a.go:1 (main.f)"} {
+		if _, ok := parseTruncationMarker(s); ok {
+			t.Errorf("parseTruncationMarker(%q) ok, want rejected", s)
+		}
 	}
 }
 
@@ -93,7 +109,7 @@ func TestSkipFrameFallback(t *testing.T) {
 func TestRenderTracesAllInternalFiltered(t *testing.T) {
 	var pcs [16]uintptr
 	n := runtime.Callers(0, pcs[:])
-	if got := renderTraces(pcs[:n]); got != nil {
+	if got := renderTraces(pcs[:n], 0); got != nil {
 		t.Errorf("renderTraces of only aerr/stdlib frames = %v, want nil", got)
 	}
 }
@@ -107,3 +123,30 @@ func TestFramesAllInternalFiltered(t *testing.T) {
 		t.Errorf("Frames of only aerr/stdlib frames = %v, want nil", got)
 	}
 }
+
+// TestRetryBackoff covers the delay schedule, its cap, and the jitter bound.
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+	if got := (RetryPolicy{}).backoff(1); got != 100*time.Millisecond {
+		t.Errorf("default backoff(1) = %v, want 100ms", got)
+	}
+	// Uncapped growth saturates instead of overflowing to a negative delay.
+	for _, n := range []int{38, 64, 1000} {
+		if got := (RetryPolicy{}).backoff(n); got != math.MaxInt64 {
+			t.Errorf("uncapped backoff(%d) = %v, want the maximum Duration", n, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(2); got < 10*time.Millisecond || got > 20*time.Millisecond {
+			t.Fatalf("jittered backoff(2) = %v, want within [10ms, 20ms]", got)
+		}
+	}
+}
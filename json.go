@@ -1,9 +1,13 @@
 package aerr
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 // MarshalJSON implements json.Marshaler. The key and nesting shape is the
@@ -11,33 +15,51 @@ import (
 //
 //	{"code": ..., "message": ..., "attributes": {...}, "stacktrace": [...]}
 //
-// Value encodings, however, follow encoding/json and may differ from an
-// adapter's native encoding: here durations serialize as integer
-// nanoseconds and []byte as base64, whereas the zerolog integration
-// renders durations in its configured DurationFieldUnit (milliseconds)
-// and []byte raw. Empty fields are omitted. Attribute values marshal with
-// encoding/json; values implementing error (but not json.Marshaler)
-// marshal as their message string, unmarshalable values degrade to their
-// fmt representation, and any value whose MarshalJSON, String, or Error
-// panics degrades to a "<panic: ...>" placeholder — MarshalJSON never
-// fails and never panics on an attribute value.
+// Optional keys appear when their feature is in use:
+//
+//	"fingerprint"   after the message, with FingerprintOptions.Emit
+//	"stack_raw"     in place of "stacktrace", under SetRawStacks
+//	"layer_stacks"  {"code", "message", "stacktrace"} objects of outer
+//	                layers that captured their own stacks (StackPerLayer)
+//	"wrapped_at"    the return trace, under SetReturnTrace
+//	"diagnostics"   last, when Builder.Diagnostics attached a snapshot
+//
+// [SetSchema] renames the top-level keys, as in {"exception.type": ...,
+// "exception.message": ...} under [OTelSchema]. Empty fields are omitted.
+//
+// Value encodings follow encoding/json and may differ from an adapter's
+// native encoding: here durations serialize as integer nanoseconds and
+// []byte as base64, whereas the zerolog integration renders durations in
+// its configured DurationFieldUnit (milliseconds) and []byte raw.
+// Attribute values marshal with encoding/json; values implementing error
+// (but not json.Marshaler) marshal as their message string, unmarshalable
+// values degrade to their fmt representation, and any value whose
+// MarshalJSON, String, or Error panics degrades to a "<panic: ...>"
+// placeholder — MarshalJSON never fails and never panics on an attribute
+// value.
 func (e *Error) MarshalJSON() ([]byte, error) {
 	if e == nil {
 		return []byte("null"), nil
 	}
+	return e.marshalJSON(currentSchema()), nil
+}
+
+// marshalJSON renders e's JSON object with the keys of s.
+func (e *Error) marshalJSON(s *Schema) []byte {
 	buf := make([]byte, 0, 128)
 	buf = append(buf, '{')
 	if e.code != "" {
-		buf = appendJSONField(buf, "code", e.code)
+		buf = appendJSONField(buf, s.Code, e.code)
 	}
 	if e.msg != "" {
-		buf = appendJSONField(buf, "message", e.msg)
+		buf = appendJSONField(buf, s.Message, e.msg)
+	}
+	if EmitsFingerprint() {
+		buf = appendJSONField(buf, s.Fingerprint, e.Fingerprint())
 	}
 	if len(e.attrs) > 0 {
-		if len(buf) > 1 {
-			buf = append(buf, ',')
-		}
-		buf = append(buf, `"attributes":{`...)
+		buf = appendJSONKey(buf, s.Attributes)
+		buf = append(buf, '{')
 		for i, a := range e.attrs {
 			if i > 0 {
 				buf = append(buf, ',')
@@ -49,25 +71,52 @@ func (e *Error) MarshalJSON() ([]byte, error) {
 		}
 		buf = append(buf, '}')
 	}
-	if traces := e.Traces(); len(traces) > 0 {
-		if len(buf) > 1 {
-			buf = append(buf, ',')
+	// The propagation format always carries the rendered trace: ParseJSON
+	// restores it, while a peer could not symbolize our PCs.
+	if raw, ok := e.rawStack(); ok && s != &wireSchema {
+		buf = appendJSONKey(buf, "stack_raw")
+		buf = appendRawStackJSON(buf, raw)
+	} else if traces := e.Traces(); len(traces) > 0 {
+		buf = appendJSONKey(buf, s.Stacktrace)
+		var val []byte
+		if s.StackString {
+			val, _ = json.Marshal(strings.Join(traces, "\n"))
+		} else {
+			val, _ = json.Marshal(traces)
 		}
-		buf = append(buf, `"stacktrace":`...)
-		val, _ := json.Marshal(traces)
 		buf = append(buf, val...)
 	}
-	buf = append(buf, '}')
-	return buf, nil
+	if secs := e.layerSections(); len(secs) > 0 {
+		buf = appendJSONKey(buf, s.LayerStacks)
+		val, _ := json.Marshal(sectionsJSON(secs))
+		buf = append(buf, val...)
+	}
+	if sites := e.WrappedAt(); len(sites) > 0 {
+		buf = appendJSONKey(buf, s.WrappedAt)
+		val, _ := json.Marshal(sites)
+		buf = append(buf, val...)
+	}
+	if e.diag != nil {
+		buf = appendJSONKey(buf, s.Diagnostics)
+		val, _ := json.Marshal(e.diag)
+		buf = append(buf, val...)
+	}
+	return append(buf, '}')
 }
 
-func appendJSONField(buf []byte, key, val string) []byte {
+// appendJSONKey appends key and its colon to the object in buf, preceded
+// by a comma unless it is the first member.
+func appendJSONKey(buf []byte, key string) []byte {
 	if len(buf) > 1 {
 		buf = append(buf, ',')
 	}
 	k, _ := json.Marshal(key)
 	buf = append(buf, k...)
-	buf = append(buf, ':')
+	return append(buf, ':')
+}
+
+func appendJSONField(buf []byte, key, val string) []byte {
+	buf = appendJSONKey(buf, key)
 	v, _ := json.Marshal(val)
 	buf = append(buf, v...)
 	return buf
@@ -150,3 +199,230 @@ func isNilValue(v any) bool {
 	}
 	return false
 }
+
+// JSONSchemaVersion is the version of the propagation format written by
+// [EncodeJSON] under the "aerr_schema" key. [ParseJSON] accepts payloads
+// up to this version, and payloads without the key as version 1.
+const JSONSchemaVersion = 1
+
+// EncodeJSON encodes err for propagation across a process boundary (an
+// HTTP body, a queue message) so a peer can restore it with [ParseJSON].
+// The payload is the MarshalJSON shape of err's flattened chain with an
+// "aerr_schema" version marker first:
+//
+//	{"aerr_schema":1,"code":...,"message":...,"attributes":{...},"stacktrace":[...]}
+//
+// Any error can be encoded: the message is always err.Error(), and the
+// code, attributes, and stack come from the nearest *Error in the chain,
+// following the usual merge rules. The stack is always the rendered
+// "stacktrace" list, even under [SetRawStacks]. A nil err encodes as null.
+// Like MarshalJSON, EncodeJSON never fails on an attribute value.
+func EncodeJSON(err error) ([]byte, error) {
+	if err == nil {
+		return []byte("null"), nil
+	}
+	e, ok := err.(*Error)
+	if !ok || e == nil {
+		// An empty builder flattens the chain exactly like an outer
+		// wrapping layer that contributes nothing of its own.
+		var b Builder
+		e = b.finalize(err, noStack)
+	}
+	body := e.marshalJSON(&wireSchema)
+	buf := make([]byte, 0, len(body)+16)
+	buf = append(buf, `{"aerr_schema":`...)
+	buf = strconv.AppendInt(buf, JSONSchemaVersion, 10)
+	if len(body) > 2 {
+		buf = append(buf, ',')
+	}
+	return append(buf, body[1:]...), nil
+}
+
+// ParseJSON decodes an error encoded by [EncodeJSON] or by
+// [Error.MarshalJSON] under the installed schema into an *Error with the
+// same code, message, attributes, and stack trace, so a receiving service
+// can test it with HasCode or wrap it as if it were local. See
+// [Error.UnmarshalJSON] for how values are restored. A null payload is an
+// error.
+func ParseJSON(data []byte) (*Error, error) {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil, errors.New("aerr: ParseJSON: null payload")
+	}
+	e := &Error{}
+	if err := e.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the inverse of MarshalJSON.
+// The decoded error has no cause; its message is the full chain message
+// of the original. Attributes keep their order. Numbers decode as int64
+// when integral and float64 otherwise, objects and arrays as map[string]any
+// and []any, and a string equal to [RedactedText] as a [Redacted] (the
+// plaintext never crossed the wire). The stack trace is kept as rendered
+// text: Traces returns it verbatim, Frames parses it, and an error
+// wrapping the decoded one inherits it under the deepest-stack rule. A
+// "wrapped_at" return trace and "layer_stacks" sections are kept the same
+// way, and local wraps extend them; a "diagnostics" snapshot is restored
+// and inherited.
+//
+// Keys are read as [DefaultSchema] names them, so payloads from
+// EncodeJSON always decode, and also as the schema installed with
+// [SetSchema] names them, so MarshalJSON output of the same process
+// decodes too; a stack trace rendered as one string (Schema.StackString)
+// is split back into lines. Unknown keys are ignored. A payload whose
+// "aerr_schema" marker is newer than [JSONSchemaVersion] is rejected.
+// Decode only into a fresh Error: an *Error that has already been issued
+// or rendered is immutable. A JSON null leaves e unchanged.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	var wire wireError
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return fmt.Errorf("aerr: decode error JSON: %w", err)
+	}
+	if s := currentSchema(); *s != defaultSchema {
+		if err := wire.fromSchema(data, s); err != nil {
+			return fmt.Errorf("aerr: decode error JSON: %w", err)
+		}
+	}
+	if wire.Schema != nil && (*wire.Schema < 1 || *wire.Schema > JSONSchemaVersion) {
+		return fmt.Errorf("aerr: unsupported aerr_schema version %d (max %d)", *wire.Schema, JSONSchemaVersion)
+	}
+	attrs, err := decodeAttrs(wire.Attributes)
+	if err != nil {
+		return err
+	}
+	e.code = wire.Code
+	e.msg = wire.Message
+	e.cause = nil
+	e.attrs = attrs
+	e.pcs = nil
+	e.remote = wire.Stacktrace
+	e.sites = nil
+	e.remoteSites = wire.WrappedAt
+	e.layerStacks = nil
+	e.remoteLayers = nil
+	for _, sec := range wire.LayerStacks {
+		e.remoteLayers = append(e.remoteLayers, StackSection{Code: sec.Code, Message: sec.Message, Traces: sec.Stacktrace})
+	}
+	e.diag = wire.Diagnostics
+	e.own = layerState{code: wire.Code, msg: wire.Message, attrs: attrs}
+	return nil
+}
+
+// wireError is the decoded form of an error object under defaultSchema.
+type wireError struct {
+	Schema      *int            `json:"aerr_schema"`
+	Code        string          `json:"code"`
+	Message     string          `json:"message"`
+	Attributes  json.RawMessage `json:"attributes"`
+	Stacktrace  wireStack       `json:"stacktrace"`
+	LayerStacks []sectionJSON   `json:"layer_stacks"`
+	WrappedAt   []string        `json:"wrapped_at"`
+	Diagnostics *Diagnostics    `json:"diagnostics"`
+}
+
+// fromSchema fills in the fields data carries under the keys of s where
+// they differ from defaultSchema's.
+func (w *wireError) fromSchema(data []byte, s *Schema) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	for _, f := range [...]struct {
+		key, def string
+		dst      any
+	}{
+		{s.Code, defaultSchema.Code, &w.Code},
+		{s.Message, defaultSchema.Message, &w.Message},
+		{s.Attributes, defaultSchema.Attributes, &w.Attributes},
+		{s.Stacktrace, defaultSchema.Stacktrace, &w.Stacktrace},
+		{s.LayerStacks, defaultSchema.LayerStacks, &w.LayerStacks},
+		{s.WrappedAt, defaultSchema.WrappedAt, &w.WrappedAt},
+		{s.Diagnostics, defaultSchema.Diagnostics, &w.Diagnostics},
+	} {
+		raw, ok := m[f.key]
+		if !ok || f.key == f.def {
+			continue
+		}
+		if err := json.Unmarshal(raw, f.dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wireStack decodes a stack trace written as a list of lines or, under
+// Schema.StackString, as one newline-separated string.
+type wireStack []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *wireStack) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if s != "" {
+			*w = strings.Split(s, "\n")
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(w))
+}
+
+// decodeAttrs decodes the "attributes" object in document order, which
+// encoding/json's map decoding would lose.
+func decodeAttrs(raw json.RawMessage) ([]attr, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("aerr: decode error JSON: attributes is not an object")
+	}
+	var attrs []attr
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("aerr: decode error JSON: %w", err)
+		}
+		key, _ := tok.(string)
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("aerr: decode error JSON: attribute %q: %w", key, err)
+		}
+		attrs = append(attrs, attr{key: key, val: wireValue(v)})
+	}
+	return attrs, nil
+}
+
+// wireValue converts a value decoded with UseNumber into the types
+// UnmarshalJSON documents.
+func wireValue(v any) any {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case string:
+		if val == RedactedText {
+			return Redacted{}
+		}
+		return val
+	case map[string]any:
+		for k, sub := range val {
+			val[k] = wireValue(sub)
+		}
+		return val
+	case []any:
+		for i, sub := range val {
+			val[i] = wireValue(sub)
+		}
+		return val
+	}
+	return v
+}
@@ -0,0 +1,129 @@
+package aerr_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+func TestParseJSONRoundTrip(t *testing.T) {
+	orig := aerr.Code("UPSTREAM").
+		Message("call failed").
+		StackTrace().
+		With("user_id", "42").
+		With("attempt", 3).
+		With("ratio", 0.5).
+		With("password", aerr.Redact("hunter2")).
+		With("tags", []string{"a", "b"}).
+		Err(errors.New("refused"))
+
+	wire, err := aerr.EncodeJSON(orig)
+	if err != nil {
+		t.Fatalf("EncodeJSON: %v", err)
+	}
+	if !strings.HasPrefix(string(wire), `{"aerr_schema":1,"code":"UPSTREAM"`) {
+		t.Errorf("wire = %s, want schema marker first", wire)
+	}
+
+	got, err := aerr.ParseJSON(wire)
+	if err != nil {
+		t.Fatalf("ParseJSON: %v", err)
+	}
+	if got.Code() != "UPSTREAM" || got.Error() != "call failed: refused" {
+		t.Errorf("code/message = %q/%q", got.Code(), got.Error())
+	}
+	keys, vals := rangeAttrs(t, got)
+	if want := []string{"user_id", "attempt", "ratio", "password", "tags"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v (order preserved)", keys, want)
+	}
+	wantVals := []any{"42", int64(3), 0.5, aerr.Redacted{}, []any{"a", "b"}}
+	if !reflect.DeepEqual(vals, wantVals) {
+		t.Errorf("vals = %#v, want %#v", vals, wantVals)
+	}
+
+	origErr, _ := aerr.AsAerr(orig)
+	if !reflect.DeepEqual(got.Traces(), origErr.Traces()) {
+		t.Errorf("Traces() = %v, want the remote trace %v", got.Traces(), origErr.Traces())
+	}
+	frames := got.Frames()
+	if len(frames) == 0 || !strings.HasSuffix(frames[0].Function, "TestParseJSONRoundTrip") || frames[0].Line == 0 {
+		t.Errorf("Frames()[0] = %+v, want the parsed origin frame", frames)
+	}
+}
+
+func TestParsedErrorWrapsLikeLocal(t *testing.T) {
+	remote, err := aerr.ParseJSON([]byte(`{"code":"DB","message":"boom","attributes":{"q":"x"},"stacktrace":["/srv/db.go:10 (svc/db.Query)"]}`))
+	if err != nil {
+		t.Fatalf("ParseJSON: %v", err)
+	}
+	wrapped := aerr.Code("API").Message("handler").StackTrace().With("route", "/u").Wrap(remote)
+
+	if !aerr.HasCode(wrapped, "DB") {
+		t.Error("HasCode(wrapped, DB) = false")
+	}
+	e, _ := aerr.AsAerr(wrapped)
+	if got := e.Traces(); !reflect.DeepEqual(got, []string{"/srv/db.go:10 (svc/db.Query)"}) {
+		t.Errorf("outer Traces() = %v, want the inherited remote trace", got)
+	}
+	if got := e.Attributes(); got["q"] != "x" || got["route"] != "/u" {
+		t.Errorf("Attributes() = %v", got)
+	}
+}
+
+func TestEncodeJSONNonAerr(t *testing.T) {
+	inner := aerr.Code("IN").With("k", 1).Err(nil)
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, `null`},
+		{"plain", errors.New("plain"), `{"aerr_schema":1,"message":"plain"}`},
+		{"fmt wrapper", fmt.Errorf("outer: %w", inner), `{"aerr_schema":1,"code":"IN","message":"outer: ","attributes":{"k":1}}`},
+		{"empty", aerr.Message("").Err(nil), `{"aerr_schema":1}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := aerr.EncodeJSON(tc.err)
+			if err != nil {
+				t.Fatalf("EncodeJSON: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("EncodeJSON = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONRejects(t *testing.T) {
+	for _, in := range []string{
+		`null`,
+		`{"aerr_schema":99,"message":"future"}`,
+		`{"attributes":[1,2]}`,
+		`not json`,
+	} {
+		if e, err := aerr.ParseJSON([]byte(in)); err == nil {
+			t.Errorf("ParseJSON(%s) = %v, want an error", in, e)
+		}
+	}
+}
+
+func TestUnmarshalJSONViaEncodingJSON(t *testing.T) {
+	var payload struct {
+		Err *aerr.Error `json:"err"`
+	}
+	if err := json.Unmarshal([]byte(`{"err":{"code":"C","message":"m"}}`), &payload); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if payload.Err.Code() != "C" || payload.Err.Error() != "m" {
+		t.Errorf("decoded = %q/%q, want C/m", payload.Err.Code(), payload.Err.Error())
+	}
+	if payload.Err.Traces() != nil {
+		t.Errorf("Traces() = %v, want nil without a stacktrace", payload.Err.Traces())
+	}
+}
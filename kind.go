@@ -0,0 +1,145 @@
+package aerr
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Kind is a registered error code together with a default message,
+// attributes, and stack-capture setting. It is a template: every Builder
+// it produces starts from the kind's state, and errors issued from it
+// match the kind with errors.Is anywhere in a chain.
+//
+// A Kind is immutable after [Define] returns and safe for concurrent use,
+// unlike a [Builder]; declare kinds as package-level variables:
+//
+//	var ErrUserNotFound = aerr.Define("USER_NOT_FOUND",
+//		aerr.KindMessage("user not found"))
+//
+//	err := ErrUserNotFound.With("user_id", id).Err(nil)
+//	errors.Is(err, ErrUserNotFound) // true
+type Kind struct {
+	tmpl Builder
+}
+
+// KindOption configures a Kind during [Define].
+type KindOption func(*Kind)
+
+// KindMessage sets the message every error of the kind starts with.
+func KindMessage(msg string) KindOption {
+	return func(k *Kind) {
+		k.tmpl.msg = msg
+	}
+}
+
+// KindStackTrace enables stack capture for every error of the kind.
+func KindStackTrace() KindOption {
+	return func(k *Kind) {
+		k.tmpl.captureStack = true
+	}
+}
+
+// KindWith adds a default attribute to every error of the kind, following
+// the same rules as [Builder.With] (including [RedactKeys]).
+func KindWith(key string, value any) KindOption {
+	return func(k *Kind) {
+		k.tmpl.With(key, value)
+	}
+}
+
+// kinds is the process-global registry of defined codes. Define runs from
+// package initialization, which may be concurrent across goroutines only
+// in unusual programs, so a plain mutex is enough.
+var kinds struct {
+	mu    sync.Mutex
+	codes map[string]*Kind
+}
+
+// Define registers a new error kind under code and returns it. Codes are
+// unique process-wide: Define panics when code is empty or already
+// registered, so a duplicated or mistyped code fails at init rather than
+// going unnoticed.
+func Define(code string, opts ...KindOption) *Kind {
+	if code == "" {
+		panic("aerr: Define with empty code")
+	}
+	k := &Kind{tmpl: Builder{code: code}}
+	for _, opt := range opts {
+		opt(k)
+	}
+	kinds.mu.Lock()
+	defer kinds.mu.Unlock()
+	if _, dup := kinds.codes[code]; dup {
+		panic(fmt.Sprintf("aerr: Define called twice for code %q", code))
+	}
+	if kinds.codes == nil {
+		kinds.codes = make(map[string]*Kind)
+	}
+	kinds.codes[code] = k
+	return k
+}
+
+// LookupKind returns the kind registered under code, if any.
+func LookupKind(code string) (*Kind, bool) {
+	kinds.mu.Lock()
+	defer kinds.mu.Unlock()
+	k, ok := kinds.codes[code]
+	return k, ok
+}
+
+// Code returns the kind's error code.
+func (k *Kind) Code() string {
+	return k.tmpl.code
+}
+
+// Error implements error so a Kind can be passed as the target of
+// errors.Is. It returns the kind's code.
+func (k *Kind) Error() string {
+	return k.tmpl.code
+}
+
+// New returns a fresh Builder initialized from the kind. The builder owns
+// its state, so further setters never affect the kind or other builders.
+func (k *Kind) New() *Builder {
+	b := k.tmpl
+	if len(k.tmpl.attrs) > 0 {
+		b.attrs = make([]attr, len(k.tmpl.attrs), len(k.tmpl.attrs)+4)
+		copy(b.attrs, k.tmpl.attrs)
+	}
+	return &b
+}
+
+// With is a shortcut for New().With(key, value).
+func (k *Kind) With(key string, value any) *Builder {
+	return k.New().With(key, value)
+}
+
+// Err finalizes an error of the kind, following the same rules as
+// [Builder.Err].
+func (k *Kind) Err(cause error) error {
+	b := k.tmpl
+	return b.finalize(cause, finalizeSkip)
+}
+
+// Wrap finalizes an error of the kind wrapping err, following the same
+// rules as [Builder.Wrap]. Returns nil when err is nil.
+func (k *Kind) Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	b := k.tmpl
+	return b.finalize(err, finalizeSkip)
+}
+
+// Is reports whether target is a [*Kind] whose code equals e's code. It
+// lets a Kind act as an errors.Is target: since errors.Is visits every
+// layer of the chain (including errors.Join trees and non-aerr wrappers),
+// a kind matches when any aerr layer carries its code, the same layers
+// [HasCode] checks.
+func (e *Error) Is(target error) bool {
+	k, ok := target.(*Kind)
+	if !ok || k == nil || e == nil {
+		return false
+	}
+	return e.code != "" && e.code == k.tmpl.code
+}
@@ -0,0 +1,141 @@
+package aerr_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+var (
+	errKindNotFound = aerr.Define("KIND_NOT_FOUND",
+		aerr.KindMessage("user not found"),
+		aerr.KindWith("entity", "user"))
+	errKindConflict = aerr.Define("KIND_CONFLICT")
+	errKindStack    = aerr.Define("KIND_STACK", aerr.KindStackTrace())
+)
+
+func TestKindErrCarriesTemplate(t *testing.T) {
+	err := errKindNotFound.With("user_id", 7).Err(nil)
+
+	e, ok := aerr.AsAerr(err)
+	if !ok {
+		t.Fatal("expected aerr")
+	}
+	if e.Code() != "KIND_NOT_FOUND" {
+		t.Errorf("Code() = %q, want KIND_NOT_FOUND", e.Code())
+	}
+	if e.Error() != "user not found" {
+		t.Errorf("Error() = %q, want %q", e.Error(), "user not found")
+	}
+	attrs := e.Attributes()
+	if attrs["entity"] != "user" || attrs["user_id"] != 7 {
+		t.Errorf("Attributes() = %v, want entity and user_id", attrs)
+	}
+}
+
+func TestKindErrorsIs(t *testing.T) {
+	err := errKindNotFound.Err(nil)
+	if !errors.Is(err, errKindNotFound) {
+		t.Error("errors.Is(err, kind) = false, want true")
+	}
+	if errors.Is(err, errKindConflict) {
+		t.Error("errors.Is(err, other kind) = true, want false")
+	}
+
+	// A deeper layer's code matches even when an outer code overrides it
+	// and a non-aerr wrapper sits in between.
+	wrapped := aerr.Code("OUTER").Wrap(fmt.Errorf("ctx: %w", err))
+	if !errors.Is(wrapped, errKindNotFound) {
+		t.Error("errors.Is through wrappers = false, want true")
+	}
+	if !aerr.HasCode(wrapped, errKindNotFound.Code()) {
+		t.Error("HasCode disagrees with errors.Is")
+	}
+
+	joined := errors.Join(errors.New("other"), errKindConflict.Wrap(errors.New("dup")))
+	if !errors.Is(joined, errKindConflict) {
+		t.Error("errors.Is through errors.Join = false, want true")
+	}
+
+	// Builders with the same code match too: a kind compares codes, not
+	// identity.
+	if !errors.Is(aerr.Code("KIND_CONFLICT").Err(nil), errKindConflict) {
+		t.Error("errors.Is(plain builder with same code, kind) = false, want true")
+	}
+}
+
+func TestKindWrapNil(t *testing.T) {
+	if err := errKindConflict.Wrap(nil); err != nil {
+		t.Errorf("Wrap(nil) = %v, want nil", err)
+	}
+}
+
+func TestKindNewDoesNotMutateTemplate(t *testing.T) {
+	errKindNotFound.New().With("entity", "overwritten").With("extra", 1).Err(nil)
+
+	e, _ := aerr.AsAerr(errKindNotFound.Err(nil))
+	attrs := e.Attributes()
+	if attrs["entity"] != "user" {
+		t.Errorf("template attribute mutated: entity = %v", attrs["entity"])
+	}
+	if _, ok := attrs["extra"]; ok {
+		t.Error("template gained an attribute from a derived builder")
+	}
+}
+
+func TestKindStackTrace(t *testing.T) {
+	e, _ := aerr.AsAerr(errKindStack.Err(nil))
+	traces := e.Traces()
+	if len(traces) == 0 || !strings.Contains(traces[0], "TestKindStackTrace") {
+		t.Errorf("first frame should be the Kind.Err call site, got %v", traces)
+	}
+}
+
+func TestKindConcurrentUse(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := errKindNotFound.With("i", i).Err(nil)
+			if !errors.Is(err, errKindNotFound) {
+				t.Error("errors.Is = false under concurrency")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDefinePanics(t *testing.T) {
+	cases := []struct {
+		name string
+		code string
+	}{
+		{"duplicate", "KIND_NOT_FOUND"},
+		{"empty", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Define(%q) did not panic", tc.code)
+				}
+			}()
+			aerr.Define(tc.code)
+		})
+	}
+}
+
+func TestLookupKind(t *testing.T) {
+	k, ok := aerr.LookupKind("KIND_CONFLICT")
+	if !ok || k != errKindConflict {
+		t.Errorf("LookupKind = %v, %v; want the defined kind", k, ok)
+	}
+	if _, ok := aerr.LookupKind("KIND_NEVER_DEFINED"); ok {
+		t.Error("LookupKind found an undefined code")
+	}
+}
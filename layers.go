@@ -0,0 +1,80 @@
+package aerr
+
+import "log/slog"
+
+// layerState is the unflattened contribution of one builder: the code,
+// message fragment, and attributes it set itself, and whether it captured
+// the stack rather than inheriting one.
+type layerState struct {
+	code     string
+	msg      string
+	attrs    []attr
+	captured bool
+}
+
+// Layer describes what a single aerr layer of a chain contributed before
+// wrapping flattened it into the outermost *Error. Fields hold only the
+// layer's own input: a layer that inherited its code from a cause reports
+// an empty Code, and Attrs omits attributes merged in from inner layers.
+// Non-aerr errors have no layer of their own, so the code and attributes
+// they contribute through [Coder] and [Attributer] count as the input of
+// the layer wrapping them, as do attributes taken from a context.
+type Layer struct {
+	// Code is the code set by this layer, or the code of a Coder it wraps
+	// when it set none; "" when neither did.
+	Code string
+	// Message is this layer's own message fragment, without the cause's
+	// message appended.
+	Message string
+	// Attrs are the attributes set by this layer, in insertion order.
+	Attrs []slog.Attr
+	// CapturedStack reports whether this layer captured the stack trace
+	// itself, as opposed to inheriting one or carrying none.
+	CapturedStack bool
+	// Err is the flattened *Error issued for this layer.
+	Err *Error
+}
+
+// Layers returns every aerr layer of err's chain, outermost first, walking
+// Unwrap() error, Unwrap() []error, and Cause() error links (errors.Join
+// trees are visited depth-first, in order). Non-aerr wrappers are
+// traversed but not reported. It returns nil when the chain holds no
+// *Error.
+//
+// Layers undoes the flattening wrapping performs, for provenance questions
+// such as which layer replaced an inner code:
+//
+//	for _, l := range aerr.Layers(err) {
+//		fmt.Println(l.Code, l.Message)
+//	}
+func Layers(err error) []Layer {
+	var out []Layer
+	walkLayers(err, func(e *Error) {
+		l := Layer{
+			Code:          e.own.code,
+			Message:       e.own.msg,
+			CapturedStack: e.own.captured,
+			Err:           e,
+		}
+		if len(e.own.attrs) > 0 {
+			l.Attrs = make([]slog.Attr, len(e.own.attrs))
+			for i, a := range e.own.attrs {
+				l.Attrs[i] = slog.Any(a.key, a.val)
+			}
+		}
+		out = append(out, l)
+	})
+	return out
+}
+
+// walkLayers calls fn for each non-nil *Error in err's chain, outermost
+// first. It is built on walkChain, so it follows the same links as AsAerr
+// and HasCode, Cause() included.
+func walkLayers(err error, fn func(*Error)) {
+	walkChain(err, func(err error) bool {
+		if e, ok := err.(*Error); ok && e != nil {
+			fn(e)
+		}
+		return false
+	})
+}
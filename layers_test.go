@@ -0,0 +1,105 @@
+package aerr_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// layerSummary is the comparable part of an aerr.Layer.
+type layerSummary struct {
+	code, msg string
+	keys      []string
+	captured  bool
+}
+
+func summarize(layers []aerr.Layer) []layerSummary {
+	out := make([]layerSummary, len(layers))
+	for i, l := range layers {
+		s := layerSummary{code: l.Code, msg: l.Message, captured: l.CapturedStack}
+		for _, a := range l.Attrs {
+			s.keys = append(s.keys, a.Key)
+		}
+		out[i] = s
+	}
+	return out
+}
+
+func TestLayersUndoesFlattening(t *testing.T) {
+	db := aerr.Code("DB_ERROR").
+		Message("query failed").
+		StackTrace().
+		With("query", "SELECT 1").
+		Err(errors.New("timeout"))
+	repo := aerr.Code("REPO_ERROR").Message("find user").With("user_id", 7).Wrap(db)
+	svc := aerr.Message("get user").With("op", "Get").StackTrace().Wrap(fmt.Errorf("svc: %w", repo))
+
+	got := summarize(aerr.Layers(svc))
+	want := []layerSummary{
+		{code: "", msg: "get user", keys: []string{"op"}},
+		{code: "REPO_ERROR", msg: "find user", keys: []string{"user_id"}},
+		{code: "DB_ERROR", msg: "query failed", keys: []string{"query"}, captured: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Layers() returned %d layers, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if fmt.Sprint(got[i]) != fmt.Sprint(want[i]) {
+			t.Errorf("layer %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// The flattened view is unaffected: the outer layer inherited the code
+	// and every attribute.
+	e, _ := aerr.AsAerr(svc)
+	if e.Code() != "REPO_ERROR" || e.NumAttrs() != 3 {
+		t.Errorf("flattened Code()=%q NumAttrs()=%d, want REPO_ERROR and 3", e.Code(), e.NumAttrs())
+	}
+}
+
+func TestLayersAttrValuesAreOwn(t *testing.T) {
+	inner := aerr.Code("IN").With("id", "inner").Err(nil)
+	outer := aerr.Code("OUT").With("id", "outer").Wrap(inner)
+
+	layers := aerr.Layers(outer)
+	if len(layers) != 2 {
+		t.Fatalf("Layers() returned %d layers, want 2", len(layers))
+	}
+	if v := layers[0].Attrs[0].Value.Any(); v != "outer" {
+		t.Errorf("outer id = %v, want outer", v)
+	}
+	if v := layers[1].Attrs[0].Value.Any(); v != "inner" {
+		t.Errorf("inner id = %v, want inner (dropped from the flattened view)", v)
+	}
+	if layers[0].Err != outer || layers[1].Err != inner {
+		t.Error("Layer.Err does not point at the issued errors")
+	}
+}
+
+func TestLayersJoinAndShortcuts(t *testing.T) {
+	joined := errors.Join(
+		aerr.ErrMsg("first"),
+		errors.New("plain"),
+		aerr.Wrapf(aerr.Errorf("second %d", 2), "wrapped"),
+	)
+	got := summarize(aerr.Layers(joined))
+	want := []layerSummary{
+		{msg: "first"},
+		{msg: "wrapped"},
+		{msg: "second 2"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Layers() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLayersNoAerr(t *testing.T) {
+	if got := aerr.Layers(errors.New("plain")); got != nil {
+		t.Errorf("Layers(plain) = %v, want nil", got)
+	}
+	if got := aerr.Layers(nil); got != nil {
+		t.Errorf("Layers(nil) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,143 @@
+package aerr
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// MergePolicy selects how wrapping resolves an attribute key that both the
+// outer builder and the wrapped inner error carry. Keys present on only
+// one side are always kept, outer attributes first. An attribute both
+// sides took from a context (see [Builder.Ctx]) with an equal value is
+// not a conflict: when the same request context is attached at every
+// layer, its "request_id" stays one value under every policy.
+type MergePolicy int
+
+const (
+	// MergeDefault defers to the process-global policy installed with
+	// [SetMergePolicy]. It is a builder's initial setting.
+	MergeDefault MergePolicy = iota
+	// MergeOuterWins keeps the outer value and drops the inner one. It is
+	// the global policy unless SetMergePolicy changes it.
+	MergeOuterWins
+	// MergeInnerWins replaces the outer value with the inner one, keeping
+	// the key at the outer position.
+	MergeInnerWins
+	// MergeNamespace keeps both: the inner attribute is appended under the
+	// key "<code>.<key>", where <code> is the inner error's code in lower
+	// case ("cause" when it has none), so a DB inner error's "query"
+	// becomes "db.query". When the namespaced key is itself taken, the
+	// outer value wins.
+	MergeNamespace
+	// MergeCollect keeps both values as a []any{outer, inner} at the outer
+	// position. Conflicts across further layers extend the same list, so
+	// three layers setting a key give []any{outer, middle, inner}; a []any
+	// attached with With is kept as one value, not spliced.
+	MergeCollect
+)
+
+// mergePolicy holds the process-global MergePolicy. Zero (MergeDefault)
+// means outer-wins, so the disabled path needs no initialization.
+var mergePolicy atomic.Int32
+
+// SetMergePolicy installs the process-global attribute merge policy used
+// by builders that did not choose one with [Builder.MergePolicy].
+// SetMergePolicy(MergeDefault) restores outer-wins.
+func SetMergePolicy(p MergePolicy) {
+	mergePolicy.Store(int32(p))
+}
+
+// MergePolicy sets how attribute key conflicts with the wrapped error are
+// resolved for this builder, overriding the global policy.
+func (b *Builder) MergePolicy(p MergePolicy) *Builder {
+	b.merge = p
+	return b
+}
+
+// mergePolicy resolves the builder's effective policy.
+func (b *Builder) mergePolicy() MergePolicy {
+	p := b.merge
+	if p == MergeDefault {
+		p = MergePolicy(mergePolicy.Load())
+	}
+	if p == MergeDefault {
+		p = MergeOuterWins
+	}
+	return p
+}
+
+// mergeAttrsWith is mergeAttrs generalized to every MergePolicy. ns is
+// the inner error's code, used by MergeNamespace. Unlike mergeAttrs,
+// MergeInnerWins and MergeCollect rewrite values already in dst, so dst
+// must not share its backing array with memory the caller keeps.
+func mergeAttrsWith(dst, src []attr, p MergePolicy, ns string) []attr {
+	if p == MergeOuterWins || len(src) == 0 {
+		return mergeAttrs(dst, src)
+	}
+	if ns == "" {
+		ns = "cause"
+	}
+	ns = strings.ToLower(ns) + "."
+next:
+	for _, a := range src {
+		for i := range dst {
+			if dst[i].key != a.key {
+				continue
+			}
+			if sameCtxAttr(dst[i], a) {
+				// Both layers were finalized with the same context; its
+				// attribute is one value, not a conflict.
+				continue next
+			}
+			switch p {
+			case MergeInnerWins:
+				dst[i].val = a.val
+			case MergeCollect:
+				dst[i].val = append(collect(nil, dst[i]), collect(nil, a)...)
+				dst[i].collected = true
+			case MergeNamespace:
+				a.key = ns + a.key
+				if !hasAttr(dst, a.key) {
+					dst = append(dst, a)
+				}
+			}
+			continue next
+		}
+		dst = append(dst, a)
+	}
+	return dst
+}
+
+// sameCtxAttr reports whether a and b both come from a context and hold
+// equal values.
+func sameCtxAttr(a, b attr) (same bool) {
+	if !a.fromCtx || !b.fromCtx {
+		return false
+	}
+	defer func() {
+		// Values of incomparable types are never the same.
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a.val == b.val
+}
+
+// collect appends a's value to list, or its elements when a holds a list
+// MergeCollect built.
+func collect(list []any, a attr) []any {
+	if vals, ok := a.val.([]any); ok && a.collected {
+		return append(list, vals...)
+	}
+	return append(list, a.val)
+}
+
+// hasAttr reports whether attrs contains key.
+func hasAttr(attrs []attr, key string) bool {
+	for i := range attrs {
+		if attrs[i].key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package aerr_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+func TestMergePolicies(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy aerr.MergePolicy
+		keys   []string
+		vals   []any
+	}{
+		{"default is outer-wins", aerr.MergeDefault, []string{"id", "op", "query"}, []any{"outer", "get", "q"}},
+		{"outer-wins", aerr.MergeOuterWins, []string{"id", "op", "query"}, []any{"outer", "get", "q"}},
+		{"inner-wins", aerr.MergeInnerWins, []string{"id", "op", "query"}, []any{"inner", "get", "q"}},
+		{"namespace", aerr.MergeNamespace, []string{"id", "op", "db.id", "query"}, []any{"outer", "get", "inner", "q"}},
+		{"collect", aerr.MergeCollect, []string{"id", "op", "query"}, []any{[]any{"outer", "inner"}, "get", "q"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			inner := aerr.Code("DB").With("id", "inner").With("query", "q").Err(nil)
+			outer := aerr.Code("SVC").
+				With("id", "outer").
+				With("op", "get").
+				MergePolicy(tc.policy).
+				Wrap(inner)
+
+			keys, vals := rangeAttrs(t, outer)
+			if !reflect.DeepEqual(keys, tc.keys) {
+				t.Errorf("keys = %v, want %v", keys, tc.keys)
+			}
+			if !reflect.DeepEqual(vals, tc.vals) {
+				t.Errorf("vals = %v, want %v", vals, tc.vals)
+			}
+		})
+	}
+}
+
+func TestMergeNamespaceWithoutCode(t *testing.T) {
+	inner := aerr.Message("").With("id", "inner").Err(nil)
+	outer := aerr.Code("SVC").With("id", "outer").MergePolicy(aerr.MergeNamespace).Wrap(inner)
+
+	keys, _ := rangeAttrs(t, outer)
+	if want := []string{"id", "cause.id"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
+
+func TestSetMergePolicyGlobal(t *testing.T) {
+	aerr.SetMergePolicy(aerr.MergeInnerWins)
+	defer aerr.SetMergePolicy(aerr.MergeDefault)
+
+	inner := aerr.Message("").With("id", "inner").Err(nil)
+
+	_, vals := rangeAttrs(t, aerr.Message("").With("id", "outer").Wrap(inner))
+	if vals[0] != "inner" {
+		t.Errorf("global inner-wins: id = %v, want inner", vals[0])
+	}
+
+	// A per-builder policy overrides the global one.
+	_, vals = rangeAttrs(t, aerr.Message("").With("id", "outer").MergePolicy(aerr.MergeOuterWins).Wrap(inner))
+	if vals[0] != "outer" {
+		t.Errorf("builder outer-wins: id = %v, want outer", vals[0])
+	}
+}
+
+// TestMergePolicyKeepsLayerAttrs confirms the in-place policies do not leak
+// the merged value into the layer's own attributes.
+func TestMergePolicyKeepsLayerAttrs(t *testing.T) {
+	inner := aerr.Message("").With("id", "inner").Err(nil)
+	outer := aerr.Message("").With("id", "outer").MergePolicy(aerr.MergeCollect).Wrap(inner)
+
+	layers := aerr.Layers(outer)
+	if v := layers[0].Attrs[0].Value.Any(); v != "outer" {
+		t.Errorf("outer layer's own id = %v, want outer", v)
+	}
+}
+
+func TestMergeCollectFlattensLayers(t *testing.T) {
+	innermost := aerr.Message("").With("id", 1).With("tags", []any{"a", "b"}).Err(nil)
+	middle := aerr.Message("").With("id", 2).With("tags", "c").MergePolicy(aerr.MergeCollect).Wrap(innermost)
+	outer := aerr.Message("").With("id", 3).MergePolicy(aerr.MergeCollect).Wrap(middle)
+
+	_, vals := rangeAttrs(t, outer)
+	if want := []any{3, 2, 1}; !reflect.DeepEqual(vals[0], want) {
+		t.Errorf("id = %v, want %v", vals[0], want)
+	}
+	// A []any attached with With is one value, not a collected list.
+	if want := []any{"c", []any{"a", "b"}}; !reflect.DeepEqual(vals[1], want) {
+		t.Errorf("tags = %v, want %v", vals[1], want)
+	}
+}
+
+func TestMergeSharedContextAttrs(t *testing.T) {
+	ctx := aerr.WithContextAttrs(context.Background(), "request_id", "r1")
+	for _, p := range []aerr.MergePolicy{aerr.MergeCollect, aerr.MergeNamespace, aerr.MergeInnerWins} {
+		inner := aerr.Code("DB").Ctx(ctx).Err(nil)
+		outer := aerr.Code("SVC").Ctx(ctx).MergePolicy(p).Wrap(inner)
+
+		keys, vals := rangeAttrs(t, outer)
+		if !reflect.DeepEqual(keys, []string{"request_id"}) || vals[0] != "r1" {
+			t.Errorf("policy %d: attrs = %v %v, want request_id=r1 once", p, keys, vals)
+		}
+	}
+
+	// A different context value still conflicts.
+	inner := aerr.Code("DB").Ctx(aerr.WithContextAttrs(context.Background(), "request_id", "r0")).Err(nil)
+	outer := aerr.Code("SVC").Ctx(ctx).MergePolicy(aerr.MergeCollect).Wrap(inner)
+	if _, vals := rangeAttrs(t, outer); !reflect.DeepEqual(vals[0], []any{"r1", "r0"}) {
+		t.Errorf("distinct contexts: request_id = %v, want both", vals[0])
+	}
+}
@@ -0,0 +1,153 @@
+package aerr
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tafaquh/aerr/internal/buildid"
+)
+
+// AnchorFunc is the function whose run-time entry address a [RawStack]
+// records as its Anchor. A symbolizer looks the function up in the binary
+// to undo address randomization of position-independent executables.
+const AnchorFunc = "github.com/tafaquh/aerr.Helper"
+
+// RawStack is a captured stack in unsymbolized form, for symbolizing
+// offline with cmd/aerr-symbolize instead of in the process.
+type RawStack struct {
+	// BuildID identifies the binary that captured the stack: its Go build
+	// ID, or "vcs.revision:<rev>" when the executable cannot be read. It
+	// is "" when neither is available.
+	BuildID string
+	// Anchor is the run-time entry address of AnchorFunc.
+	Anchor uintptr
+	// PCs are the captured return addresses, innermost first, before any
+	// frame filtering.
+	PCs []uintptr
+	// Truncated is the number of rendered frames the capture depth cut
+	// off; runtime and filtered frames are not counted.
+	Truncated int
+}
+
+// rawStacks enables raw stack emission; see SetRawStacks.
+var rawStacks atomic.Bool
+
+// SetRawStacks switches the structured renderers — JSON, slog, and the
+// zap and zerolog adapters — from the rendered "stacktrace" list to a
+// "stack_raw" object holding the raw PCs, the build ID, and the anchor
+// address:
+//
+//	"stack_raw": {"build_id": "...", "anchor": "0x4b1f20", "pcs": ["0x4b2a15", ...]}
+//
+// Logging an error then skips symbolizing its captured stack, the costly
+// part of rendering; "layer_stacks" sections, a "wrapped_at" return trace,
+// and an emitted fingerprint are still symbolized. cmd/aerr-symbolize
+// turns the object back into "stacktrace" lines given the binary. Traces,
+// Frames, %+v, and [EncodeJSON] still symbolize on demand; stacks decoded
+// with [ParseJSON] have no PCs and render as before.
+func SetRawStacks(on bool) {
+	rawStacks.Store(on)
+}
+
+// EmitsRawStacks reports whether renderers emit "stack_raw" in place of
+// "stacktrace" (see [SetRawStacks]). Logging adapters consult it.
+func EmitsRawStacks() bool {
+	return rawStacks.Load()
+}
+
+// RawStack returns the captured stack in unsymbolized form. ok is false
+// when e has no locally captured stack, including errors decoded with
+// [ParseJSON].
+func (e *Error) RawStack() (raw RawStack, ok bool) {
+	if e == nil || len(e.pcs) == 0 {
+		return RawStack{}, false
+	}
+	return RawStack{
+		BuildID:   BuildID(),
+		Anchor:    reflect.ValueOf(Helper).Pointer(),
+		PCs:       e.pcs,
+		Truncated: e.truncated,
+	}, true
+}
+
+// rawStack returns e's raw stack when renderers should emit it in place of
+// the rendered trace.
+func (e *Error) rawStack() (RawStack, bool) {
+	if !rawStacks.Load() {
+		return RawStack{}, false
+	}
+	return e.RawStack()
+}
+
+// Limit returns r cut after the PCs of its first n rendered frames, with
+// the rendered frames cut off added to Truncated, so it counts like a
+// capture depth of n. Frames are rendered under the current FramePolicy,
+// which symbolizes the PCs; n <= 0 returns r unchanged.
+func (r RawStack) Limit(n int) RawStack {
+	if n <= 0 {
+		return r
+	}
+	kept := 0
+	for i := range r.PCs {
+		if kept >= n {
+			r.Truncated += keptFrames(r.PCs[i:])
+			r.PCs = r.PCs[:i:i]
+			break
+		}
+		kept += keptFrames(r.PCs[i : i+1])
+	}
+	return r
+}
+
+// HexPCs returns PCs formatted as "0x"-prefixed hexadecimal, the form
+// written under "stack_raw".
+func (r RawStack) HexPCs() []string {
+	out := make([]string, len(r.PCs))
+	for i, pc := range r.PCs {
+		out[i] = hexAddr(pc)
+	}
+	return out
+}
+
+// hexAddr formats an address as "0x"-prefixed hexadecimal.
+func hexAddr(pc uintptr) string {
+	return "0x" + strconv.FormatUint(uint64(pc), 16)
+}
+
+// appendRawStackJSON appends the "stack_raw" object value.
+func appendRawStackJSON(buf []byte, r RawStack) []byte {
+	buf = append(buf, '{')
+	if r.BuildID != "" {
+		buf = append(buf, `"build_id":`...)
+		buf = strconv.AppendQuote(buf, r.BuildID)
+		buf = append(buf, ',')
+	}
+	buf = append(buf, `"anchor":"`...)
+	buf = append(buf, hexAddr(r.Anchor)...)
+	buf = append(buf, `","pcs":[`...)
+	for i, pc := range r.PCs {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, '"')
+		buf = append(buf, hexAddr(pc)...)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, ']')
+	if r.Truncated > 0 {
+		buf = append(buf, `,"truncated":`...)
+		buf = strconv.AppendInt(buf, int64(r.Truncated), 10)
+	}
+	return append(buf, '}')
+}
+
+// BuildID returns the identifier recorded in a [RawStack]: the Go build
+// ID of the running executable, or "vcs.revision:<rev>" from the build
+// info when the executable cannot be read, or "". It is computed once.
+func BuildID() string {
+	return buildID()
+}
+
+var buildID = sync.OnceValue(buildid.Self)
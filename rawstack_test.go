@@ -0,0 +1,151 @@
+package aerr_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+func TestRawStacksReplaceStacktrace(t *testing.T) {
+	aerr.SetRawStacks(true)
+	defer aerr.SetRawStacks(false)
+
+	e, _ := aerr.AsAerr(aerr.Code("RAW").StackTraceDepth(2).Err(nil))
+	raw, ok := e.RawStack()
+	if !ok || len(raw.PCs) != 2 || raw.Anchor == 0 {
+		t.Fatalf("RawStack() = %+v, %v", raw, ok)
+	}
+	if runtime.GOOS == "linux" && raw.BuildID == "" {
+		t.Error("BuildID is empty on linux")
+	}
+
+	data, _ := json.Marshal(e)
+	var got struct {
+		Stacktrace []string `json:"stacktrace"`
+		StackRaw   struct {
+			BuildID   string   `json:"build_id"`
+			Anchor    string   `json:"anchor"`
+			PCs       []string `json:"pcs"`
+			Truncated int      `json:"truncated"`
+		} `json:"stack_raw"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Stacktrace != nil {
+		t.Errorf("stacktrace emitted with raw stacks on: %s", data)
+	}
+	if got.StackRaw.BuildID != raw.BuildID || got.StackRaw.Truncated != raw.Truncated {
+		t.Errorf("stack_raw = %+v, want %+v", got.StackRaw, raw)
+	}
+	if got.StackRaw.Anchor != fmt.Sprintf("%#x", raw.Anchor) {
+		t.Errorf("anchor = %q, want %#x", got.StackRaw.Anchor, raw.Anchor)
+	}
+	for i, h := range got.StackRaw.PCs {
+		pc, err := strconv.ParseUint(strings.TrimPrefix(h, "0x"), 16, 64)
+		if err != nil || uintptr(pc) != raw.PCs[i] {
+			t.Errorf("pcs[%d] = %q, want %#x", i, h, raw.PCs[i])
+		}
+	}
+	if len(e.Traces()) == 0 {
+		t.Error("Traces must still symbolize on demand")
+	}
+}
+
+func TestRawStacksEncodeJSONRoundTrip(t *testing.T) {
+	aerr.SetRawStacks(true)
+	defer aerr.SetRawStacks(false)
+
+	e, _ := aerr.AsAerr(aerr.Code("RAW").StackTrace().Err(nil))
+	data, _ := aerr.EncodeJSON(e)
+	if strings.Contains(string(data), "stack_raw") {
+		t.Errorf("EncodeJSON emitted stack_raw: %s", data)
+	}
+	got, err := aerr.ParseJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Traces(), e.Traces()) {
+		t.Errorf("decoded Traces() = %v, want %v", got.Traces(), e.Traces())
+	}
+}
+
+func TestRawStacksLogValue(t *testing.T) {
+	aerr.SetRawStacks(true)
+	defer aerr.SetRawStacks(false)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Error("failed", "err", aerr.Code("RAW").StackTrace().Err(nil))
+
+	var line struct {
+		Err map[string]json.RawMessage `json:"err"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := line.Err["stacktrace"]; ok {
+		t.Errorf("stacktrace emitted with raw stacks on: %s", buf.Bytes())
+	}
+	var raw struct {
+		Anchor string   `json:"anchor"`
+		PCs    []string `json:"pcs"`
+	}
+	if err := json.Unmarshal(line.Err["stack_raw"], &raw); err != nil || raw.Anchor == "" || len(raw.PCs) == 0 {
+		t.Errorf("stack_raw = %s (%v)", line.Err["stack_raw"], err)
+	}
+}
+
+func TestRawStacksWithoutCapture(t *testing.T) {
+	aerr.SetRawStacks(true)
+	defer aerr.SetRawStacks(false)
+
+	e, _ := aerr.AsAerr(aerr.Code("NONE").Err(nil))
+	if _, ok := e.RawStack(); ok {
+		t.Error("RawStack ok without a captured stack")
+	}
+	data, _ := json.Marshal(e)
+	if strings.Contains(string(data), "stack") {
+		t.Errorf("JSON = %s, want no stack keys", data)
+	}
+
+	// Decoded errors have no PCs and keep their rendered trace.
+	remote, err := aerr.ParseJSON([]byte(`{"code":"R","stacktrace":["a.go:1 (main.f)"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(remote)
+	if !strings.Contains(string(data), `"stacktrace":["a.go:1 (main.f)"]`) {
+		t.Errorf("decoded error JSON = %s", data)
+	}
+}
+
+func TestRawStackLimitCountsRenderedFrames(t *testing.T) {
+	e, _ := aerr.AsAerr(nest(6, func() error {
+		return aerr.Code("DEEP").StackTrace().Err(nil)
+	}))
+	raw, _ := e.RawStack()
+	rendered := len(e.Traces())
+	if raw.Truncated > 0 {
+		rendered-- // the "... N more frames" marker
+	}
+
+	got := raw.Limit(2)
+	if want := raw.Truncated + rendered - 2; got.Truncated != want {
+		t.Errorf("Limit(2).Truncated = %d, want %d", got.Truncated, want)
+	}
+	if len(got.PCs) == 0 || len(got.PCs) >= len(raw.PCs) {
+		t.Errorf("Limit(2) kept %d of %d PCs", len(got.PCs), len(raw.PCs))
+	}
+	if same := raw.Limit(0); len(same.PCs) != len(raw.PCs) || same.Truncated != raw.Truncated {
+		t.Errorf("Limit(0) = %+v, want %+v", same, raw)
+	}
+}
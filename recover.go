@@ -0,0 +1,76 @@
+package aerr
+
+import "fmt"
+
+// PanicCode is the code [Recover] gives errors built from a recovered
+// panic.
+const PanicCode = "PANIC"
+
+// Recover converts a panic in the calling function into an *Error stored
+// in *errp, for use directly in a defer statement:
+//
+//	func handle() (err error) {
+//		defer aerr.Recover(&err)
+//		...
+//	}
+//
+// The error has code [PanicCode] and message "panic: <value>". A panic
+// value that is an error becomes the cause, so errors.Is/As and aerr's
+// chain merging see it; any other value is attached as the "panic"
+// attribute. The stack is captured at the panic site, not at the recover
+// site — unless the panic value is an *Error that already carries a
+// trace, which is inherited under the deepest-stack rule. An error
+// already in *errp is replaced. When there is no panic Recover does
+// nothing; when errp is nil the panic is re-raised.
+func Recover(errp *error) {
+	if r := recover(); r != nil {
+		setPanicError(errp, PanicCode, r)
+	}
+}
+
+// RecoverCode is [Recover] with a custom error code in place of
+// PanicCode.
+func RecoverCode(errp *error, code string) {
+	if r := recover(); r != nil {
+		setPanicError(errp, code, r)
+	}
+}
+
+// Go runs fn in a new goroutine and delivers its result on the returned
+// channel, which receives exactly one value and is never closed. A panic
+// in fn is recovered and delivered as an error, as with [Recover].
+func Go(fn func() error) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		var err error
+		defer func() { ch <- err }()
+		defer Recover(&err)
+		err = fn()
+	}()
+	return ch
+}
+
+// panicSkip is the number of frames between runtime.Callers and the
+// panicking function on the recover path: runtime.Callers, captureStack,
+// finalize, setPanicError, Recover / RecoverCode, and runtime.gopanic.
+// Deferred calls run on top of the panicking frames, which are still on
+// the stack while the panic unwinds.
+const panicSkip = 6
+
+// setPanicError builds the panic error for Recover and RecoverCode. It
+// must be called directly from them so panicSkip stays accurate.
+func setPanicError(errp *error, code string, r any) {
+	if errp == nil {
+		panic(r)
+	}
+	b := Builder{code: code, captureStack: true}
+	var cause error
+	if err, ok := r.(error); ok {
+		b.msg = "panic"
+		cause = err
+	} else {
+		b.msg = "panic: " + fmt.Sprint(r)
+		b.With("panic", r)
+	}
+	*errp = b.finalize(cause, panicSkip)
+}
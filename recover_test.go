@@ -0,0 +1,109 @@
+package aerr_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+//go:noinline
+func panicsWith(v any) {
+	panic(v)
+}
+
+func recoverFrom(v any) (err error) {
+	defer aerr.Recover(&err)
+	panicsWith(v)
+	return nil
+}
+
+func TestRecoverValue(t *testing.T) {
+	err := recoverFrom("boom")
+	e, ok := aerr.AsAerr(err)
+	if !ok {
+		t.Fatalf("Recover produced %T, want *aerr.Error", err)
+	}
+	if e.Code() != aerr.PanicCode || e.Error() != "panic: boom" {
+		t.Errorf("code/message = %q/%q, want PANIC/panic: boom", e.Code(), e.Error())
+	}
+	if got := e.Attributes()["panic"]; got != "boom" {
+		t.Errorf("panic attribute = %v, want boom", got)
+	}
+
+	// The first user frame is the panicking function, not the deferring
+	// one or Recover.
+	frames := e.Frames()
+	if len(frames) == 0 || !strings.HasSuffix(frames[0].Function, ".panicsWith") {
+		t.Errorf("first frame = %+v, want panicsWith", frames)
+	}
+}
+
+func TestRecoverError(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	err := recoverFrom(sentinel)
+	if !errors.Is(err, sentinel) {
+		t.Error("errors.Is(err, panic value) = false, want the value as cause")
+	}
+	if err.Error() != "panic: sentinel" {
+		t.Errorf("message = %q, want panic: sentinel", err.Error())
+	}
+
+	// An aerr panic value keeps its metadata; the code still reports the
+	// panic, and the inner code stays visible to HasCode.
+	err = recoverFrom(aerr.Code("INNER").With("k", "v").ErrMsg("bad"))
+	e, _ := aerr.AsAerr(err)
+	if e.Code() != aerr.PanicCode || !aerr.HasCode(err, "INNER") || e.Attributes()["k"] != "v" {
+		t.Errorf("aerr panic value: code=%q attrs=%v", e.Code(), e.Attributes())
+	}
+}
+
+func TestRecoverRuntimeError(t *testing.T) {
+	var err error
+	func() {
+		defer aerr.RecoverCode(&err, "CRASH")
+		var m map[string]int
+		m["x"] = 1
+	}()
+	e, ok := aerr.AsAerr(err)
+	if !ok || e.Code() != "CRASH" || !strings.Contains(e.Error(), "nil map") {
+		t.Fatalf("RecoverCode = %v (%v)", err, ok)
+	}
+}
+
+func TestRecoverNoPanic(t *testing.T) {
+	want := errors.New("kept")
+	err := func() (err error) {
+		defer aerr.Recover(&err)
+		return want
+	}()
+	if err != want {
+		t.Errorf("Recover without panic changed the error to %v", err)
+	}
+}
+
+func TestRecoverNilPointerRepanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "again" {
+			t.Errorf("recovered %v, want the re-raised panic", r)
+		}
+	}()
+	defer aerr.Recover(nil)
+	panic("again")
+}
+
+func TestGo(t *testing.T) {
+	if err := <-aerr.Go(func() error { return nil }); err != nil {
+		t.Errorf("Go(nil-returning fn) = %v", err)
+	}
+	want := errors.New("failed")
+	if err := <-aerr.Go(func() error { return want }); err != want {
+		t.Errorf("Go(error-returning fn) = %v, want %v", err, want)
+	}
+	err := <-aerr.Go(func() error { panicsWith(42); return nil })
+	e, ok := aerr.AsAerr(err)
+	if !ok || e.Code() != aerr.PanicCode || e.Attributes()["panic"] != 42 {
+		t.Errorf("Go(panicking fn) = %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package aerr
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Retryable marks the error as transient, so [IsRetryable] reports true
+// for any chain containing it and [Retry] tries again.
+func (b *Builder) Retryable() *Builder {
+	b.retryable = true
+	return b
+}
+
+// RetryAfter marks the error as retryable no sooner than d from now,
+// reported by the package-level [RetryAfter] and honored by [Retry]. It
+// implies Retryable.
+func (b *Builder) RetryAfter(d time.Duration) *Builder {
+	b.retryable = true
+	b.retryAfter = d
+	return b
+}
+
+// IsRetryable reports whether err's chain signals a transient failure: an
+// *Error built with Retryable or RetryAfter, or any error whose
+// Temporary() or Timeout() method returns true (the net.Error convention).
+// Like [HasCode] it checks every layer individually, walking both
+// Unwrap() error and Unwrap() []error links, since retry classification is
+// not inherited when wrapping.
+func IsRetryable(err error) bool {
+	return walkChain(err, func(err error) bool {
+		if e, ok := err.(*Error); ok && e != nil && e.retryable {
+			return true
+		}
+		if t, ok := err.(interface{ Temporary() bool }); ok && t.Temporary() {
+			return true
+		}
+		if t, ok := err.(interface{ Timeout() bool }); ok && t.Timeout() {
+			return true
+		}
+		return false
+	})
+}
+
+// RetryAfter returns the delay set with [Builder.RetryAfter] on the
+// outermost aerr layer of err's chain that has one. ok is false when no
+// layer carries a delay.
+func RetryAfter(err error) (d time.Duration, ok bool) {
+	walkChain(err, func(err error) bool {
+		if e, isAerr := err.(*Error); isAerr && e != nil && e.retryAfter > 0 {
+			d, ok = e.retryAfter, true
+		}
+		return ok
+	})
+	return d, ok
+}
+
+// RetryPolicy configures [Retry]. Zero fields take the documented
+// defaults, so RetryPolicy{} is a usable policy.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of calls, including the first.
+	// Default 3.
+	MaxAttempts int
+	// InitialDelay is the wait after the first failed attempt. Default
+	// 100ms.
+	InitialDelay time.Duration
+	// Multiplier scales the delay after each further attempt. Default 2.
+	Multiplier float64
+	// MaxDelay caps the backoff delay. Zero means no cap. A longer
+	// RetryAfter hint from the error is still honored.
+	MaxDelay time.Duration
+	// Jitter randomizes each delay downward by up to this fraction (0.2
+	// waits between 80% and 100% of the backoff), spreading out retries
+	// from many clients. Zero disables it; values above 1 count as 1.
+	Jitter float64
+}
+
+// backoff returns the delay before attempt n+1, after n failed attempts.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.InitialDelay)
+	if d <= 0 {
+		d = float64(100 * time.Millisecond)
+	}
+	mult := p.Multiplier
+	if mult <= 0 {
+		mult = 2
+	}
+	for i := 1; i < n; i++ {
+		d *= mult
+		if p.MaxDelay > 0 && d >= float64(p.MaxDelay) || d >= math.MaxInt64 {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if j := min(p.Jitter, 1); j > 0 {
+		// Jitter only spreads load; it needs no cryptographic randomness.
+		d -= d * j * rand.Float64() //nolint:gosec
+	}
+	if d >= math.MaxInt64 {
+		// Without MaxDelay the doubling outgrows time.Duration, and the
+		// conversion would wrap around to a negative delay.
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// Retry calls fn until it succeeds, returns an error [IsRetryable] rejects,
+// exhausts policy.MaxAttempts, or ctx is done, waiting an exponentially
+// growing, jittered delay between attempts (or the error's [RetryAfter]
+// hint when that is longer). It returns nil on success. Otherwise the last
+// error is wrapped in an aerr layer carrying the "attempts" attribute,
+// keeping its code, attributes, and stack; when ctx ends the wait, ctx's
+// error is joined to it so errors.Is(err, context.Canceled) holds.
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= maxAttempts || !IsRetryable(err) {
+			return retryFailed(err, attempt)
+		}
+		delay := policy.backoff(attempt)
+		if hint, ok := RetryAfter(err); ok && hint > delay {
+			delay = hint
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return retryFailed(fmt.Errorf("%w: %w", err, ctx.Err()), attempt)
+		case <-timer.C:
+		}
+	}
+}
+
+// retryFailed wraps Retry's final error with the attempt count. It must be
+// called directly from Retry: the skip counts retryFailed and Retry on top
+// of the usual finalize frames.
+func retryFailed(err error, attempts int) error {
+	var b Builder
+	b.With("attempts", attempts)
+	return b.finalize(err, finalizeSkip+1)
+}
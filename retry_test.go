@@ -0,0 +1,126 @@
+package aerr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tafaquh/aerr"
+)
+
+// timeoutErr mimics net.Error's classification methods.
+type timeoutErr struct{ timeout, temporary bool }
+
+func (e timeoutErr) Error() string   { return "i/o timeout" }
+func (e timeoutErr) Timeout() bool   { return e.timeout }
+func (e timeoutErr) Temporary() bool { return e.temporary }
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain", errors.New("x"), false},
+		{"aerr not marked", aerr.Code("X").Err(nil), false},
+		{"marked", aerr.Code("X").Retryable().Err(nil), true},
+		{"retry after implies retryable", aerr.Code("X").RetryAfter(time.Second).Err(nil), true},
+		{"marked inner, unmarked outer", aerr.Code("OUT").Wrap(fmt.Errorf("w: %w", aerr.Code("IN").Retryable().Err(nil))), true},
+		{"foreign timeout", aerr.Code("X").Wrap(timeoutErr{timeout: true}), true},
+		{"foreign temporary", timeoutErr{temporary: true}, true},
+		{"foreign neither", timeoutErr{}, false},
+		{"join", errors.Join(errors.New("a"), aerr.Code("B").Retryable().Err(nil)), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := aerr.IsRetryable(tc.err); got != tc.want {
+				t.Errorf("IsRetryable = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfterOutermostWins(t *testing.T) {
+	inner := aerr.Code("IN").RetryAfter(time.Minute).Err(nil)
+	if d, ok := aerr.RetryAfter(aerr.Code("OUT").Wrap(inner)); !ok || d != time.Minute {
+		t.Errorf("RetryAfter(inner only) = %v, %v; want 1m", d, ok)
+	}
+	outer := aerr.Code("OUT").RetryAfter(time.Second).Wrap(inner)
+	if d, ok := aerr.RetryAfter(outer); !ok || d != time.Second {
+		t.Errorf("RetryAfter(both) = %v, %v; want 1s", d, ok)
+	}
+	if _, ok := aerr.RetryAfter(errors.New("x")); ok {
+		t.Error("RetryAfter(plain) ok = true")
+	}
+}
+
+var fastPolicy = aerr.RetryPolicy{MaxAttempts: 4, InitialDelay: time.Millisecond, Jitter: 0.5}
+
+func TestRetrySucceeds(t *testing.T) {
+	calls := 0
+	err := aerr.Retry(context.Background(), fastPolicy, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return aerr.Code("FLAKY").Retryable().Err(nil)
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Retry = %v after %d calls, want nil after 3", err, calls)
+	}
+}
+
+func TestRetryStopsOnPermanentError(t *testing.T) {
+	calls := 0
+	err := aerr.Retry(context.Background(), fastPolicy, func(context.Context) error {
+		calls++
+		return aerr.Code("BAD_INPUT").With("field", "email").Err(nil)
+	})
+	if calls != 1 {
+		t.Errorf("permanent error retried: %d calls", calls)
+	}
+	e, _ := aerr.AsAerr(err)
+	if e.Code() != "BAD_INPUT" || e.Attributes()["attempts"] != 1 || e.Attributes()["field"] != "email" {
+		t.Errorf("final error code=%q attrs=%v", e.Code(), e.Attributes())
+	}
+}
+
+func TestRetryExhausts(t *testing.T) {
+	calls := 0
+	err := aerr.Retry(context.Background(), fastPolicy, func(context.Context) error {
+		calls++
+		return aerr.Code("FLAKY").Retryable().Err(nil)
+	})
+	e, _ := aerr.AsAerr(err)
+	if calls != 4 || e.Attributes()["attempts"] != 4 || !aerr.IsRetryable(err) {
+		t.Errorf("calls=%d attrs=%v", calls, e.Attributes())
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := aerr.Retry(ctx, aerr.RetryPolicy{MaxAttempts: 5, InitialDelay: time.Hour}, func(context.Context) error {
+		calls++
+		cancel()
+		return aerr.Code("FLAKY").Retryable().Err(nil)
+	})
+	if calls != 1 || !errors.Is(err, context.Canceled) || !aerr.HasCode(err, "FLAKY") {
+		t.Errorf("Retry after cancel = %v (%d calls)", err, calls)
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	start := time.Now()
+	calls := 0
+	_ = aerr.Retry(context.Background(), aerr.RetryPolicy{MaxAttempts: 2, InitialDelay: time.Nanosecond}, func(context.Context) error {
+		calls++
+		return aerr.Code("SLOW_DOWN").RetryAfter(20 * time.Millisecond).Err(nil)
+	})
+	if elapsed := time.Since(start); calls != 2 || elapsed < 20*time.Millisecond {
+		t.Errorf("waited %v over %d calls, want at least the 20ms hint", elapsed, calls)
+	}
+}
@@ -0,0 +1,70 @@
+package aerr
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// returnTrace enables recording wrap sites; see SetReturnTrace.
+var returnTrace atomic.Bool
+
+// SetReturnTrace turns the return trace on or off process-wide. While on,
+// every error issued by a builder or a shortcut (Err, Wrap, ErrMsg,
+// Errorf, Wrapf, ...) records the single call site that issued it, and
+// wrapping appends to the sites the wrapped error recorded. The result,
+// read with [Error.WrappedAt], is the path the error took from its origin
+// to the outermost wrap, at the cost of one PC per layer instead of a full
+// stack capture. Sites recorded while the mode was on are kept after it is
+// turned off.
+func SetReturnTrace(on bool) {
+	returnTrace.Store(on)
+}
+
+// returnSite returns the PC of the call site skip frames up, counted as
+// for captureStack, passing over frames of functions marked with Helper.
+// It returns 0 when the stack is shallower than skip.
+func returnSite(skip int) uintptr {
+	if !helpers.any.Load() {
+		var pc [1]uintptr
+		if runtime.Callers(skip, pc[:]) == 0 {
+			return 0
+		}
+		return pc[0]
+	}
+	var pcs [8]uintptr
+	n := runtime.Callers(skip, pcs[:])
+	if i := leadingHelpers(pcs[:n]); i < n {
+		return pcs[i]
+	}
+	return 0
+}
+
+// WrappedAt returns the return trace recorded under [SetReturnTrace]: the
+// call sites that issued each aerr layer of the chain, origin first and
+// outermost last, rendered as "file:line (function)" like Traces (file
+// paths follow the [FramePolicy]). It returns nil when no site was
+// recorded. For an error decoded with [ParseJSON] the sender's sites come
+// first, verbatim. The rendering is computed on first use and cached;
+// callers must treat the returned slice as read-only.
+func (e *Error) WrappedAt() []string {
+	if e == nil {
+		return nil
+	}
+	if len(e.sites) == 0 {
+		return e.remoteSites
+	}
+	e.sitesOnce.Do(func() {
+		policy := framePolicy.Load()
+		out := make([]string, len(e.remoteSites), len(e.remoteSites)+len(e.sites))
+		copy(out, e.remoteSites)
+		var buf []byte
+		for _, pc := range e.sites {
+			f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+			f.File = policy.file(f)
+			buf = appendFrame(buf[:0], f)
+			out = append(out, string(buf))
+		}
+		e.wrappedAt = out
+	})
+	return e.wrappedAt
+}
@@ -0,0 +1,148 @@
+package aerr_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+func rtOrigin() error {
+	return aerr.Code("NOT_FOUND").Message("no row").Err(nil)
+}
+
+func rtRepository() error {
+	return aerr.Wrapf(rtOrigin(), "load user %d", 7)
+}
+
+func rtService() error {
+	// A non-aerr wrapper in between records nothing but is traversed.
+	return aerr.Message("service").Wrap(fmt.Errorf("repo: %w", rtRepository()))
+}
+
+func rtHelper(err error) error {
+	aerr.Helper()
+	return aerr.Message("helped").Wrap(err)
+}
+
+// assertSites checks that sites name the given functions in order.
+func assertSites(t *testing.T, sites []string, funcs ...string) {
+	t.Helper()
+	if len(sites) != len(funcs) {
+		t.Fatalf("WrappedAt() = %q, want %d sites", sites, len(funcs))
+	}
+	for i, fn := range funcs {
+		if !strings.HasSuffix(sites[i], "aerr_test."+fn+")") || !strings.Contains(sites[i], "returntrace_test.go:") {
+			t.Errorf("WrappedAt()[%d] = %q, want a returntrace_test.go site in %s", i, sites[i], fn)
+		}
+	}
+}
+
+func TestReturnTraceRecordsWrapSites(t *testing.T) {
+	aerr.SetReturnTrace(true)
+	defer aerr.SetReturnTrace(false)
+
+	e, _ := aerr.AsAerr(rtService())
+	assertSites(t, e.WrappedAt(), "rtOrigin", "rtRepository", "rtService")
+	if len(e.Traces()) != 0 {
+		t.Error("return trace mode must not capture full stacks")
+	}
+}
+
+func TestReturnTraceShortcutsAndHelpers(t *testing.T) {
+	aerr.SetReturnTrace(true)
+	defer aerr.SetReturnTrace(false)
+
+	for name, err := range map[string]error{
+		"ErrMsg": aerr.ErrMsg("m"),
+		"Errorf": aerr.Errorf("m %d", 1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			e, _ := aerr.AsAerr(err)
+			assertSites(t, e.WrappedAt(), "TestReturnTraceShortcutsAndHelpers")
+		})
+	}
+
+	e, _ := aerr.AsAerr(rtHelper(aerr.ErrMsg("m")))
+	assertSites(t, e.WrappedAt(), "TestReturnTraceShortcutsAndHelpers", "TestReturnTraceShortcutsAndHelpers")
+}
+
+func TestReturnTraceOff(t *testing.T) {
+	e, _ := aerr.AsAerr(rtService())
+	if sites := e.WrappedAt(); sites != nil {
+		t.Errorf("WrappedAt() = %q with the mode off, want nil", sites)
+	}
+	data, _ := json.Marshal(e)
+	if strings.Contains(string(data), "wrapped_at") {
+		t.Errorf("JSON has wrapped_at with the mode off: %s", data)
+	}
+
+	// Sites recorded while on survive turning it off, without growing.
+	aerr.SetReturnTrace(true)
+	inner := rtOrigin()
+	aerr.SetReturnTrace(false)
+	outer, _ := aerr.AsAerr(aerr.Message("outer").Wrap(inner))
+	assertSites(t, outer.WrappedAt(), "rtOrigin")
+}
+
+func TestReturnTraceRendered(t *testing.T) {
+	aerr.SetReturnTrace(true)
+	err := rtService()
+	aerr.SetReturnTrace(false)
+	e, _ := aerr.AsAerr(err)
+	sites := e.WrappedAt()
+
+	var lv []string
+	for _, a := range e.LogValue().Group() {
+		if a.Key == "wrapped_at" {
+			lv, _ = a.Value.Any().([]string)
+		}
+	}
+	if strings.Join(lv, "|") != strings.Join(sites, "|") {
+		t.Errorf("LogValue wrapped_at = %q, want %q", lv, sites)
+	}
+
+	detail := fmt.Sprintf("%+v", err)
+	if !strings.Contains(detail, "\nwrapped_at:\n    "+sites[0]) {
+		t.Errorf("%%+v lacks the wrapped_at section:\n%s", detail)
+	}
+
+	data, _ := json.Marshal(err)
+	var wire struct {
+		WrappedAt []string `json:"wrapped_at"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(wire.WrappedAt, "|") != strings.Join(sites, "|") {
+		t.Errorf("JSON wrapped_at = %q, want %q", wire.WrappedAt, sites)
+	}
+}
+
+func TestReturnTraceAcrossJSON(t *testing.T) {
+	aerr.SetReturnTrace(true)
+	defer aerr.SetReturnTrace(false)
+
+	sent, _ := aerr.AsAerr(rtService())
+	data, _ := aerr.EncodeJSON(sent)
+	received, err := aerr.ParseJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ := aerr.AsAerr(aerr.Message("gateway").Wrap(received))
+	got := e.WrappedAt()
+	want := append(append([]string(nil), sent.WrappedAt()...), "")
+	if len(got) != len(want) {
+		t.Fatalf("WrappedAt() after decode and wrap = %q, want the sender's %d sites plus one", got, len(want)-1)
+	}
+	for i := range sent.WrappedAt() {
+		if got[i] != want[i] {
+			t.Errorf("site %d = %q, want the sender's %q", i, got[i], want[i])
+		}
+	}
+	if !strings.Contains(got[len(got)-1], "TestReturnTraceAcrossJSON") {
+		t.Errorf("local wrap site = %q", got[len(got)-1])
+	}
+}
@@ -0,0 +1,135 @@
+package aerr
+
+import "sync/atomic"
+
+// Schema names the top-level keys the structured renderers — LogValue,
+// MarshalJSON, and the zap and zerolog adapters — emit for an *Error. An
+// empty field keeps the key of [DefaultSchema], so a custom schema names
+// only the keys it changes:
+//
+//	aerr.SetSchema(aerr.Schema{Code: "error_code", Stacktrace: "stack"})
+//
+// Keys are emitted verbatim, dots included; backends that expand dotted
+// keys (Elasticsearch, OpenTelemetry collectors) see nested fields. The
+// "stack_raw" object of [SetRawStacks] and the entries of "layer_stacks"
+// keep their fixed shape, as does [EncodeJSON]: the propagation format
+// does not depend on the schema. [ParseJSON] reads both the default keys
+// and those of the installed schema.
+type Schema struct {
+	Code        string
+	Message     string
+	Fingerprint string
+	Attributes  string
+	Stacktrace  string
+	LayerStacks string
+	WrappedAt   string
+	Diagnostics string
+	// StackString renders the stack trace as one newline-separated string
+	// instead of a list of frames, the form the presets' backends expect.
+	StackString bool
+}
+
+// DefaultSchema returns aerr's own layout: code, message, attributes,
+// stacktrace. It is in effect until [SetSchema] is called.
+func DefaultSchema() Schema { return defaultSchema }
+
+// OTelSchema returns the preset following the OpenTelemetry exception
+// semantic conventions: the code is reported as exception.type.
+func OTelSchema() Schema {
+	return Schema{
+		Code:        "exception.type",
+		Message:     "exception.message",
+		Stacktrace:  "exception.stacktrace",
+		StackString: true,
+	}
+}
+
+// ECSSchema returns the preset following the Elastic Common Schema error
+// fields.
+func ECSSchema() Schema {
+	return Schema{
+		Code:        "error.code",
+		Message:     "error.message",
+		Stacktrace:  "error.stack_trace",
+		StackString: true,
+	}
+}
+
+// DatadogSchema returns the preset following Datadog's standard error
+// attributes: the code is reported as error.kind, and the fingerprint as
+// error.fingerprint, which Error Tracking groups by.
+func DatadogSchema() Schema {
+	return Schema{
+		Code:        "error.kind",
+		Message:     "error.message",
+		Fingerprint: "error.fingerprint",
+		Stacktrace:  "error.stack",
+		StackString: true,
+	}
+}
+
+// defaultSchema backs DefaultSchema; unexported so no caller can change
+// the keys other packages rely on.
+var defaultSchema = Schema{
+	Code:        "code",
+	Message:     "message",
+	Fingerprint: "fingerprint",
+	Attributes:  "attributes",
+	Stacktrace:  "stacktrace",
+	LayerStacks: "layer_stacks",
+	WrappedAt:   "wrapped_at",
+	Diagnostics: "diagnostics",
+}
+
+// wireSchema is the key layout of EncodeJSON, fixed whatever SetSchema
+// installs. It is a variable of its own so renderers can tell the wire
+// format apart by address.
+var wireSchema = defaultSchema
+
+// schemaPtr holds the process-global schema with its empty keys filled in;
+// nil means defaultSchema.
+var schemaPtr atomic.Pointer[Schema]
+
+// SetSchema installs the process-global schema used by the structured
+// renderers, for example aerr.SetSchema(aerr.OTelSchema()).
+func SetSchema(s Schema) {
+	s = s.withDefaults()
+	schemaPtr.Store(&s)
+}
+
+// CurrentSchema returns the schema installed with [SetSchema], with every
+// key filled in. Adapters outside this package consult it so every render
+// path agrees.
+func CurrentSchema() Schema {
+	return *currentSchema()
+}
+
+// currentSchema returns the installed schema without copying it. The
+// result points at a value only this package holds, SetSchema's own copy
+// or defaultSchema, and must not be modified.
+func currentSchema() *Schema {
+	if s := schemaPtr.Load(); s != nil {
+		return s
+	}
+	return &defaultSchema
+}
+
+// withDefaults fills s's empty keys from defaultSchema.
+func (s Schema) withDefaults() Schema {
+	d := defaultSchema
+	for _, kv := range [...]struct{ key, def *string }{
+		{&s.Code, &d.Code},
+		{&s.Message, &d.Message},
+		{&s.Fingerprint, &d.Fingerprint},
+		{&s.Attributes, &d.Attributes},
+		{&s.Stacktrace, &d.Stacktrace},
+		{&s.LayerStacks, &d.LayerStacks},
+		{&s.WrappedAt, &d.WrappedAt},
+		{&s.Diagnostics, &d.Diagnostics},
+	} {
+		if *kv.key == "" {
+			*kv.key = *kv.def
+		}
+	}
+	return s
+}
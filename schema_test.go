@@ -0,0 +1,132 @@
+package aerr_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+func TestSchemaPresetsRenameKeys(t *testing.T) {
+	defer aerr.SetSchema(aerr.DefaultSchema())
+	err := aerr.Code("NOT_FOUND").Message("no user").With("user_id", 7).StackTrace().Err(nil)
+	e, _ := aerr.AsAerr(err)
+	stack := strings.Join(e.Traces(), "\n")
+
+	for _, tc := range []struct {
+		name                 string
+		schema               aerr.Schema
+		code, message, trace string
+	}{
+		{"otel", aerr.OTelSchema(), "exception.type", "exception.message", "exception.stacktrace"},
+		{"ecs", aerr.ECSSchema(), "error.code", "error.message", "error.stack_trace"},
+		{"datadog", aerr.DatadogSchema(), "error.kind", "error.message", "error.stack"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			aerr.SetSchema(tc.schema)
+			data, _ := json.Marshal(err)
+			var got map[string]any
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got[tc.code] != "NOT_FOUND" || got[tc.message] != "no user" || got[tc.trace] != stack {
+				t.Errorf("MarshalJSON = %s", data)
+			}
+			if attrs, _ := got["attributes"].(map[string]any); attrs["user_id"] != float64(7) {
+				t.Errorf("attributes = %v", got["attributes"])
+			}
+			if _, ok := got["code"]; ok {
+				t.Errorf("default code key emitted: %s", data)
+			}
+
+			var buf bytes.Buffer
+			slog.New(slog.NewJSONHandler(&buf, nil)).Error("failed", "err", err)
+			var line struct {
+				Err map[string]any `json:"err"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatal(err)
+			}
+			if line.Err[tc.code] != "NOT_FOUND" || line.Err[tc.message] != "no user" || line.Err[tc.trace] != stack {
+				t.Errorf("LogValue = %s", buf.Bytes())
+			}
+		})
+	}
+}
+
+func TestSchemaCustomKeysKeepDefaults(t *testing.T) {
+	defer aerr.SetSchema(aerr.DefaultSchema())
+	aerr.SetSchema(aerr.Schema{Code: "error_code", Attributes: "fields"})
+
+	s := aerr.CurrentSchema()
+	if s.Code != "error_code" || s.Message != "message" || s.Stacktrace != "stacktrace" {
+		t.Errorf("CurrentSchema() = %+v", s)
+	}
+	data, _ := json.Marshal(aerr.Code("C").Message("m").With("k", 1).StackTrace().Err(nil))
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"error_code", "message", "fields", "stacktrace"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("%s missing from %s", key, data)
+		}
+	}
+	if !bytes.HasPrefix(got["stacktrace"], []byte("[")) {
+		t.Errorf("stacktrace = %s, want a list", got["stacktrace"])
+	}
+}
+
+func TestSchemaPresetsAreCopies(t *testing.T) {
+	d := aerr.DefaultSchema()
+	d.Code = "changed"
+	o := aerr.OTelSchema()
+	o.Code = "changed"
+	c := aerr.CurrentSchema()
+	c.Code = "changed"
+	if aerr.DefaultSchema().Code != "code" || aerr.OTelSchema().Code != "exception.type" || aerr.CurrentSchema().Code != "code" {
+		t.Error("changing a returned schema changed the package's")
+	}
+	data, _ := json.Marshal(aerr.Code("C").Err(nil))
+	if !strings.Contains(string(data), `"code":"C"`) {
+		t.Errorf("MarshalJSON = %s", data)
+	}
+}
+
+func TestSchemaLeavesPropagationFormat(t *testing.T) {
+	defer aerr.SetSchema(aerr.DefaultSchema())
+	aerr.SetSchema(aerr.OTelSchema())
+
+	data, err := aerr.EncodeJSON(aerr.Code("REMOTE").Message("m").With("k", "v").StackTrace().Err(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"code":"REMOTE"`)) {
+		t.Errorf("EncodeJSON = %s, want default keys", data)
+	}
+	e, err := aerr.ParseJSON(data)
+	if err != nil || e.Code() != "REMOTE" || e.Error() != "m" || len(e.Traces()) == 0 {
+		t.Errorf("ParseJSON = %v, %v", e, err)
+	}
+}
+
+func TestSchemaMarshalJSONRoundTrip(t *testing.T) {
+	defer aerr.SetSchema(aerr.DefaultSchema())
+	aerr.SetSchema(aerr.OTelSchema())
+
+	orig, _ := aerr.AsAerr(aerr.Code("LOCAL").Message("m").With("k", "v").StackTrace().Err(nil))
+	data, _ := json.Marshal(orig)
+	e, err := aerr.ParseJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Code() != "LOCAL" || e.Error() != "m" || e.Attributes()["k"] != "v" {
+		t.Errorf("ParseJSON(%s) = %v %v", data, e.Code(), e.Attributes())
+	}
+	if got, want := strings.Join(e.Traces(), "\n"), strings.Join(orig.Traces(), "\n"); got != want {
+		t.Errorf("Traces() = %q, want %q", got, want)
+	}
+}
@@ -315,10 +315,14 @@ func TestStackDepthTruncation(t *testing.T) {
 	if len(traces) == 0 {
 		t.Fatal("expected captured traces")
 	}
-	// stackMaxDepth caps the raw capture at 32 PCs; filtering only removes
-	// frames, so the rendered trace can never exceed that.
-	if len(traces) > 32 {
-		t.Errorf("len(Traces()) = %d, want <= 32 (stackMaxDepth)", len(traces))
+	// DefaultStackDepth caps the raw capture at 32 PCs; filtering only
+	// removes frames, so the rendered trace can never exceed that plus the
+	// truncation marker.
+	if len(traces) > aerr.DefaultStackDepth+1 {
+		t.Errorf("len(Traces()) = %d, want <= %d", len(traces), aerr.DefaultStackDepth+1)
+	}
+	if e.TruncatedFrames() == 0 || !strings.HasPrefix(traces[len(traces)-1], "... ") {
+		t.Errorf("45-deep capture not marked truncated: last entry %q", traces[len(traces)-1])
 	}
 	// The deepest frames are kept (origin preserved); the recursive function
 	// must appear, and the very first frame is its capture site.
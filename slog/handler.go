@@ -0,0 +1,429 @@
+// Package aerrslog provides a log/slog Handler middleware that recognizes
+// aerr errors in record attributes and applies aerr-specific policy
+// before passing the record on:
+//
+//	logger := slog.New(aerrslog.NewHandler(slog.NewJSONHandler(os.Stdout, nil),
+//		aerrslog.LiftCode("error_code"),
+//		aerrslog.CodeLevel("NOT_FOUND", slog.LevelInfo),
+//		aerrslog.ExpectedCodes("NOT_FOUND", "VALIDATION"),
+//		aerrslog.Deduplicate(),
+//	))
+//
+// Without options the handler is transparent: an *aerr.Error renders
+// through its LogValue exactly as it would without the middleware.
+package aerrslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/tafaquh/aerr"
+)
+
+// Option configures a Handler.
+type Option func(*config)
+
+type config struct {
+	codeKey        string
+	fingerprintKey string
+	levels         map[string]slog.Level
+	expected       []string
+	dedupe         bool
+	stackOut       io.Writer
+}
+
+// LiftCode adds the code of the record's first aerr error as a top-level
+// attribute under key, so log queries can filter on it without reaching
+// into the error group. It stays top-level under groups opened with
+// WithGroup.
+func LiftCode(key string) Option {
+	return func(c *config) { c.codeKey = key }
+}
+
+// LiftFingerprint adds the fingerprint of the record's first aerr error
+// (see aerr.Error.Fingerprint) as a top-level attribute under key.
+func LiftFingerprint(key string) Option {
+	return func(c *config) { c.fingerprintKey = key }
+}
+
+// CodeLevel logs records whose first aerr error carries code at level
+// instead of the level they were logged at, raising or lowering it. When
+// several layers of the error have a level, the outermost wins, as in
+// aerrhttp.StatusOf. A record lowered below the wrapped handler's minimum
+// level is dropped.
+func CodeLevel(code string, level slog.Level) Option {
+	return func(c *config) {
+		if c.levels == nil {
+			c.levels = make(map[string]slog.Level)
+		}
+		c.levels[code] = level
+	}
+}
+
+// ExpectedCodes marks codes that are part of normal operation, such as a
+// lookup miss: an aerr error carrying one of them in any layer (see
+// aerr.HasCode) renders without its stack trace.
+func ExpectedCodes(codes ...string) Option {
+	return func(c *config) { c.expected = append(c.expected, codes...) }
+}
+
+// Deduplicate drops an attribute whose error is the same value as one an
+// earlier attribute of the record already carries, as when a call site
+// passes an error both as "err" and inside a group.
+func Deduplicate() Option {
+	return func(c *config) { c.dedupe = true }
+}
+
+// MultilineStack moves stack traces out of the record and writes them to
+// w after it, one indented frame per line, for a slog.TextHandler writing
+// to the same w (which would otherwise print the trace as one quoted
+// line):
+//
+//	h := aerrslog.NewHandler(slog.NewTextHandler(os.Stderr, nil), aerrslog.MultilineStack(os.Stderr))
+//
+// Records pass to the wrapped handler one at a time, so each is followed
+// by its own traces. Each trace is headed by the attribute key it came
+// from:
+//
+//	time=... level=ERROR msg=failed err.code=NOT_FOUND err.message="no row"
+//	    err.stacktrace:
+//	        /app/repo/user.go:42 (repo.FindUser)
+//	        /app/main.go:17 (main.main)
+func MultilineStack(w io.Writer) Option {
+	return func(c *config) { c.stackOut = w }
+}
+
+// Handler is a slog.Handler that applies its options to aerr errors found
+// in record attributes, including inside groups, and passes the record to
+// the wrapped handler. An attribute holds an aerr error when its value is
+// an error that carries an *aerr.Error or contributes through aerr.Coder or
+// aerr.Attributer (see aerr.From); it is rendered from the error's
+// LogValue. Attributes added with WithAttrs are treated like the record's
+// own, ahead of them. Lifted attributes are top-level whatever groups are
+// open: once a group is opened under LiftCode or LiftFingerprint, the
+// Handler applies later groups and attributes to each record itself
+// rather than handing them to the wrapped handler.
+type Handler struct {
+	next slog.Handler
+	c    *config
+	// prefix joins the groups already opened on next, for stack keys.
+	prefix string
+	// pending holds the groups and attributes not handed to next, oldest
+	// first.
+	pending []groupOrAttrs
+	// mu serializes records under MultilineStack, including those without
+	// stacks, so no record lands between another and its stack lines.
+	mu *sync.Mutex
+}
+
+// groupOrAttrs is one WithGroup or WithAttrs call kept by the Handler.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// NewHandler returns a Handler passing records to next.
+func NewHandler(next slog.Handler, opts ...Option) *Handler {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &Handler{next: next, c: c, mu: &sync.Mutex{}}
+}
+
+// Enabled reports whether the wrapped handler is enabled at level, or at
+// a level a CodeLevel option could raise a record to.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.next.Enabled(ctx, level) {
+		return true
+	}
+	for _, l := range h.c.levels {
+		if l > level && h.next.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle applies the options to r and passes it to the wrapped handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	st := recordState{c: h.c}
+	// Rewrite the pending attributes in log order, then nest them with the
+	// record's own from the innermost group outward.
+	path := h.prefix
+	pending := make([][]slog.Attr, len(h.pending))
+	for i, goa := range h.pending {
+		if goa.group != "" {
+			path = joinKey(path, goa.group)
+			continue
+		}
+		pending[i] = st.attrs(goa.attrs, path)
+	}
+	attrs := make([]slog.Attr, 0, r.NumAttrs()+2)
+	r.Attrs(func(a slog.Attr) bool {
+		if a, ok := st.attr(a, path); ok {
+			attrs = append(attrs, a)
+		}
+		return true
+	})
+	for i := len(h.pending) - 1; i >= 0; i-- {
+		if g := h.pending[i].group; g != "" {
+			attrs = []slog.Attr{{Key: g, Value: slog.GroupValue(attrs...)}}
+		} else {
+			attrs = append(pending[i], attrs...)
+		}
+	}
+	if len(h.pending) > 0 {
+		st.changed = true
+	}
+	level := r.Level
+	if st.first != nil {
+		level = h.level(st.first, level)
+		attrs = append(st.lifted(), attrs...)
+	}
+	// Enabled lets records through for levels CodeLevel might raise them
+	// to; drop those that did not end up enabled.
+	if (level != r.Level || len(h.c.levels) > 0) && !h.next.Enabled(ctx, level) {
+		return nil
+	}
+	if st.changed {
+		nr := slog.NewRecord(r.Time, level, r.Message, r.PC)
+		nr.AddAttrs(attrs...)
+		r = nr
+	}
+	if h.c.stackOut == nil {
+		return h.next.Handle(ctx, r)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.next.Handle(ctx, r); err != nil || len(st.stacks) == 0 {
+		return err
+	}
+	var b strings.Builder
+	for _, s := range st.stacks {
+		b.WriteString("    ")
+		b.WriteString(s.key)
+		b.WriteString(":\n")
+		for _, line := range s.traces {
+			b.WriteString("        ")
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	_, err := io.WriteString(h.c.stackOut, b.String())
+	return err
+}
+
+// WithAttrs returns a Handler with attrs added. Attributes without an
+// error are handed to the wrapped handler while no group is pending;
+// the rest are kept and rewritten with every record.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := *h
+	if len(h.pending) == 0 && !hasError(attrs) {
+		c.next = h.next.WithAttrs(attrs)
+		return &c
+	}
+	c.pending = append(h.pending[:len(h.pending):len(h.pending)], groupOrAttrs{attrs: attrs})
+	return &c
+}
+
+// WithGroup returns a Handler with the group opened. The group is handed
+// to the wrapped handler unless attributes are pending or lifted ones
+// must stay outside it.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	if len(h.pending) == 0 && h.c.codeKey == "" && h.c.fingerprintKey == "" {
+		c.next = h.next.WithGroup(name)
+		c.prefix = joinKey(h.prefix, name)
+		return &c
+	}
+	c.pending = append(h.pending[:len(h.pending):len(h.pending)], groupOrAttrs{group: name})
+	return &c
+}
+
+// hasError reports whether any of attrs, including inside groups and
+// LogValuers, holds an error.
+func hasError(attrs []slog.Attr) bool {
+	for _, a := range attrs {
+		v := a.Value
+		if k := v.Kind(); k == slog.KindAny || k == slog.KindLogValuer {
+			if _, ok := v.Any().(error); ok {
+				return true
+			}
+			v = v.Resolve()
+		}
+		if v.Kind() == slog.KindGroup && hasError(v.Group()) {
+			return true
+		}
+	}
+	return false
+}
+
+// level returns the CodeLevel of e's outermost layer that has one, or
+// level.
+func (h *Handler) level(e *aerr.Error, level slog.Level) slog.Level {
+	if len(h.c.levels) == 0 {
+		return level
+	}
+	for _, l := range aerr.Layers(e) {
+		if lvl, ok := h.c.levels[l.Err.Code()]; ok {
+			return lvl
+		}
+	}
+	return level
+}
+
+// recordState collects what one record's attributes yield.
+type recordState struct {
+	c       *config
+	first   *aerr.Error
+	seen    []error
+	stacks  []stackOut
+	changed bool
+}
+
+// stackOut is one trace MultilineStack writes after the record.
+type stackOut struct {
+	key    string
+	traces []string
+}
+
+// attrs rewrites each of attrs under path with attr, leaving out those
+// it drops.
+func (st *recordState) attrs(attrs []slog.Attr, path string) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a, ok := st.attr(a, path); ok {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// attr rewrites a, whose enclosing groups within the record are path,
+// reporting false when it is to be dropped.
+func (st *recordState) attr(a slog.Attr, path string) (slog.Attr, bool) {
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		out := make([]slog.Attr, 0, len(group))
+		for _, ga := range group {
+			if ga, ok := st.attr(ga, joinKey(path, a.Key)); ok {
+				out = append(out, ga)
+			}
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}, true
+	case slog.KindAny, slog.KindLogValuer:
+	default:
+		return a, true
+	}
+	err, ok := a.Value.Any().(error)
+	if !ok {
+		if a.Value.Kind() == slog.KindLogValuer {
+			// Look inside values that resolve to groups of errors.
+			return st.attr(slog.Attr{Key: a.Key, Value: a.Value.Resolve()}, path)
+		}
+		return a, true
+	}
+	e, ok := aerr.From(err)
+	if !ok {
+		return a, true
+	}
+	st.changed = true
+	if st.c.dedupe {
+		if st.isSeen(err) {
+			return a, false
+		}
+		st.seen = append(st.seen, err)
+	}
+	if st.first == nil {
+		st.first = e
+	}
+	val := e.LogValue()
+	sch := aerr.CurrentSchema()
+	if st.expected(e) {
+		val, _ = without(val, sch.Stacktrace, sch.LayerStacks, "stack_raw")
+	} else if st.c.stackOut != nil {
+		var removed bool
+		if val, removed = without(val, sch.Stacktrace); removed {
+			key := joinKey(joinKey(path, a.Key), sch.Stacktrace)
+			st.stacks = append(st.stacks, stackOut{key: key, traces: e.Traces()})
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: val}, true
+}
+
+// without returns the group v without the given keys, reporting whether
+// any was present.
+func without(v slog.Value, keys ...string) (slog.Value, bool) {
+	group := v.Group()
+	out := make([]slog.Attr, 0, len(group))
+	for _, a := range group {
+		drop := false
+		for _, k := range keys {
+			drop = drop || a.Key == k
+		}
+		if !drop {
+			out = append(out, a)
+		}
+	}
+	return slog.GroupValue(out...), len(out) < len(group)
+}
+
+// expected reports whether e carries one of the ExpectedCodes.
+func (st *recordState) expected(e *aerr.Error) bool {
+	for _, code := range st.c.expected {
+		if aerr.HasCode(e, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSeen reports whether err is the same value as an error already kept.
+func (st *recordState) isSeen(err error) bool {
+	if !reflect.TypeOf(err).Comparable() {
+		return false
+	}
+	for _, prev := range st.seen {
+		if prev == err {
+			return true
+		}
+	}
+	return false
+}
+
+// lifted returns the top-level attributes lifted from the first error.
+func (st *recordState) lifted() []slog.Attr {
+	var out []slog.Attr
+	if key := st.c.codeKey; key != "" {
+		if code := st.first.Code(); code != "" {
+			out = append(out, slog.String(key, code))
+		}
+	}
+	if key := st.c.fingerprintKey; key != "" {
+		out = append(out, slog.String(key, st.first.Fingerprint()))
+	}
+	return out
+}
+
+// joinKey appends key to the dotted group path.
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	if key == "" {
+		return path
+	}
+	return path + "." + key
+}
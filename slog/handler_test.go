@@ -0,0 +1,279 @@
+package aerrslog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/tafaquh/aerr"
+	aerrslog "github.com/tafaquh/aerr/slog"
+)
+
+// newLogger returns a logger writing JSON lines through an aerrslog
+// Handler into the returned buffer, at level Info and above.
+func newLogger(opts ...aerrslog.Option) (*slog.Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	next := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	return slog.New(aerrslog.NewHandler(next, opts...)), buf
+}
+
+// decodeLines parses every JSON line in buf.
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatalf("log line is not valid JSON: %v\n%s", err, line)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestSlogtestConformance(t *testing.T) {
+	var buf bytes.Buffer
+	h := aerrslog.NewHandler(slog.NewJSONHandler(&buf, nil),
+		aerrslog.LiftCode("error_code"),
+		aerrslog.LiftFingerprint("error_fingerprint"),
+		aerrslog.CodeLevel("NOT_FOUND", slog.LevelInfo),
+		aerrslog.ExpectedCodes("NOT_FOUND"),
+		aerrslog.Deduplicate(),
+	)
+	err := slogtest.TestHandler(h, func() []map[string]any {
+		return decodeLines(t, &buf)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransparentWithoutOptions(t *testing.T) {
+	err := aerr.Code("NOT_FOUND").Message("no user").With("user_id", 7).StackTrace().Err(nil)
+
+	logger, buf := newLogger()
+	logger.Error("failed", "err", err)
+	var want bytes.Buffer
+	slog.New(slog.NewJSONHandler(&want, nil)).Error("failed", "err", err)
+
+	got, exp := decodeLines(t, buf)[0], decodeLines(t, &want)[0]
+	delete(got, "time")
+	delete(exp, "time")
+	g, _ := json.Marshal(got)
+	w, _ := json.Marshal(exp)
+	if !bytes.Equal(g, w) {
+		t.Errorf("line = %s\nwant  %s", g, w)
+	}
+}
+
+func TestLiftCodeAndFingerprint(t *testing.T) {
+	err := aerr.Code("NOT_FOUND").Message("no user").Err(nil)
+	e, _ := aerr.AsAerr(err)
+
+	logger, buf := newLogger(aerrslog.LiftCode("error_code"), aerrslog.LiftFingerprint("error_fp"))
+	logger.Error("failed", "err", err)
+
+	line := decodeLines(t, buf)[0]
+	if line["error_code"] != "NOT_FOUND" || line["error_fp"] != e.Fingerprint() {
+		t.Errorf("line = %v", line)
+	}
+	if obj, _ := line["err"].(map[string]any); obj["code"] != "NOT_FOUND" {
+		t.Errorf("err = %v, want it kept", line["err"])
+	}
+}
+
+func TestCodeLevel(t *testing.T) {
+	logger, buf := newLogger(
+		aerrslog.CodeLevel("NOT_FOUND", slog.LevelInfo),
+		aerrslog.CodeLevel("CORRUPT", slog.LevelError),
+		aerrslog.CodeLevel("NOISE", slog.LevelDebug),
+	)
+	logger.Error("lowered", "err", aerr.Code("NOT_FOUND").Err(nil))
+	logger.Debug("raised", "err", aerr.Code("CORRUPT").Err(nil))
+	logger.Error("dropped", "err", aerr.Code("NOISE").Err(nil))
+	logger.Debug("filtered", "err", aerr.Code("OTHER").Err(nil))
+	logger.Warn("outermost", "err", aerr.Code("CORRUPT").Wrap(aerr.Code("NOT_FOUND").Err(nil)))
+
+	lines := decodeLines(t, buf)
+	var got []string
+	for _, l := range lines {
+		got = append(got, l["msg"].(string)+"="+l["level"].(string))
+	}
+	want := []string{"lowered=INFO", "raised=ERROR", "outermost=ERROR"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+}
+
+func TestExpectedCodesDropStack(t *testing.T) {
+	logger, buf := newLogger(aerrslog.ExpectedCodes("NOT_FOUND"))
+	logger.Error("miss", "err", aerr.Message("lookup").Wrap(aerr.Code("NOT_FOUND").StackTrace().Err(nil)))
+	logger.Error("fault", "err", aerr.Code("DB").StackTrace().Err(nil))
+
+	lines := decodeLines(t, buf)
+	miss, _ := lines[0]["err"].(map[string]any)
+	if _, ok := miss["stacktrace"]; ok || miss["code"] != "NOT_FOUND" {
+		t.Errorf("expected error = %v, want no stacktrace", miss)
+	}
+	fault, _ := lines[1]["err"].(map[string]any)
+	if _, ok := fault["stacktrace"]; !ok {
+		t.Errorf("unexpected error = %v, want its stacktrace", fault)
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	err := aerr.Code("DUP").Err(nil)
+	other := aerr.Code("DUP").Err(nil)
+
+	logger, buf := newLogger(aerrslog.Deduplicate())
+	logger.Error("failed", "err", err, slog.Group("req", "cause", err, "other", other))
+
+	line := decodeLines(t, buf)[0]
+	if _, ok := line["err"]; !ok {
+		t.Errorf("first occurrence dropped: %v", line)
+	}
+	req, _ := line["req"].(map[string]any)
+	if _, ok := req["cause"]; ok {
+		t.Errorf("duplicate kept: %v", req)
+	}
+	if _, ok := req["other"]; !ok {
+		t.Errorf("distinct error with the same code dropped: %v", req)
+	}
+}
+
+func TestMultilineStack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(aerrslog.NewHandler(slog.NewTextHandler(&buf, nil), aerrslog.MultilineStack(&buf)))
+	err := aerr.Code("NOT_FOUND").Message("no row").StackTrace().Err(nil)
+	e, _ := aerr.AsAerr(err)
+	logger.Error("failed", "err", err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2+len(e.Traces()) {
+		t.Fatalf("output = %q", buf.String())
+	}
+	if !strings.Contains(lines[0], "err.code=NOT_FOUND") || strings.Contains(lines[0], "stacktrace") {
+		t.Errorf("record line = %q", lines[0])
+	}
+	if lines[1] != "    err.stacktrace:" || lines[2] != "        "+e.Traces()[0] {
+		t.Errorf("stack lines = %q", lines[1:])
+	}
+}
+
+func TestForeignAndPlainErrors(t *testing.T) {
+	logger, buf := newLogger(aerrslog.LiftCode("error_code"))
+	logger.Error("plain", "err", errors.New("boom"))
+	logger.Error("wrapped", "err", errors.Join(errors.New("ctx"), aerr.Code("INNER").Err(nil)))
+
+	lines := decodeLines(t, buf)
+	if _, ok := lines[0]["error_code"]; ok || lines[0]["err"] != "boom" {
+		t.Errorf("plain error = %v", lines[0])
+	}
+	if lines[1]["error_code"] != "INNER" {
+		t.Errorf("wrapped error = %v", lines[1])
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent writes. It holds
+// back stack lines briefly, giving other goroutines' records the chance to
+// land between a record and its stack.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	if bytes.HasPrefix(p, []byte("    ")) {
+		time.Sleep(time.Millisecond)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func TestMultilineStackConcurrent(t *testing.T) {
+	var out lockedBuffer
+	logger := slog.New(aerrslog.NewHandler(slog.NewTextHandler(&out, nil), aerrslog.MultilineStack(&out)))
+	err := aerr.Code("NOT_FOUND").StackTrace().Err(nil)
+	e, _ := aerr.AsAerr(err)
+	frames := len(e.Traces())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				logger.Error("stack", "err", err)
+				logger.Info("plain")
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(out.buf.String(), "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		switch {
+		case strings.Contains(lines[i], "msg=stack"):
+			if i+1+frames >= len(lines) || lines[i+1] != "    err.stacktrace:" {
+				t.Fatalf("line %d: record not followed by its stack: %q", i, lines[i:min(i+3, len(lines))])
+			}
+			for _, l := range lines[i+2 : i+2+frames] {
+				if !strings.HasPrefix(l, "        ") {
+					t.Fatalf("line %d: stack interrupted by %q", i, l)
+				}
+			}
+			i += 1 + frames
+		case strings.Contains(lines[i], "msg=plain"):
+		default:
+			t.Fatalf("line %d: unexpected %q", i, lines[i])
+		}
+	}
+}
+
+func TestLiftedStayTopLevelUnderGroups(t *testing.T) {
+	logger, buf := newLogger(aerrslog.LiftCode("error_code"))
+	logger.WithGroup("req").With("id", 7).WithGroup("db").Error("failed", "err", aerr.Code("NOT_FOUND").Err(nil))
+
+	line := decodeLines(t, buf)[0]
+	if line["error_code"] != "NOT_FOUND" {
+		t.Errorf("line = %v, want error_code at the top level", line)
+	}
+	req, _ := line["req"].(map[string]any)
+	db, _ := req["db"].(map[string]any)
+	if req["id"] != float64(7) || db["err"] == nil {
+		t.Errorf("line = %v, want the record's attributes under req.db", line)
+	}
+}
+
+func TestWithAttrsErrorsHandled(t *testing.T) {
+	err := aerr.Code("NOT_FOUND").Message("no user").StackTrace().Err(nil)
+	logger, buf := newLogger(
+		aerrslog.LiftCode("error_code"),
+		aerrslog.CodeLevel("NOT_FOUND", slog.LevelWarn),
+		aerrslog.ExpectedCodes("NOT_FOUND"),
+		aerrslog.Deduplicate(),
+	)
+	logger.With("err", err).Error("failed", "again", err)
+
+	line := decodeLines(t, buf)[0]
+	if line["error_code"] != "NOT_FOUND" || line["level"] != "WARN" {
+		t.Errorf("line = %v, want the code lifted and the level lowered", line)
+	}
+	obj, _ := line["err"].(map[string]any)
+	if _, ok := obj["stacktrace"]; ok || obj["code"] != "NOT_FOUND" {
+		t.Errorf("err = %v, want it rendered without its stack", obj)
+	}
+	if _, ok := line["again"]; ok {
+		t.Errorf("line = %v, want the repeated error dropped", line)
+	}
+}
@@ -0,0 +1,137 @@
+package aerr
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// sourceContext holds the number of source lines shown on each side of a
+// frame; zero disables snippets. See SetSourceContext.
+var sourceContext atomic.Int32
+
+// SetSourceContext makes %+v and [WriteDev] print n lines of source before
+// and after each frame of the stack trace, with the frame's own line
+// marked by ">". n <= 0 turns snippets off, the default. It is meant for
+// local development: source files are read from disk on first use, the
+// first few hundred stay cached for the life of the process, and a frame
+// whose file cannot be read — a container without sources, a -trimpath
+// build, a line past the end of an edited file — is printed without a
+// snippet.
+func SetSourceContext(n int) {
+	if n < 0 {
+		n = 0
+	}
+	sourceContext.Store(int32(n))
+}
+
+// sourceFiles caches source files by path: their lines, or nil when the
+// file could not be read. It holds at most maxSourceFiles entries, counted
+// by sourceFileCount; files beyond that are read again on every use.
+var (
+	sourceFiles     sync.Map // string -> []string
+	sourceFileCount atomic.Int32
+)
+
+// maxSourceFiles bounds the sourceFiles cache, so a process that leaves
+// snippets on does not keep every file it ever rendered in memory.
+const maxSourceFiles = 256
+
+// sourceLines returns the lines of the source file at path, or nil when it
+// is unavailable. Relative paths, which -trimpath builds report, are never
+// resolved against the working directory.
+func sourceLines(path string) []string {
+	if v, ok := sourceFiles.Load(path); ok {
+		return v.([]string)
+	}
+	var lines []string
+	if filepath.IsAbs(path) {
+		if data, err := os.ReadFile(path); err == nil {
+			text := strings.ReplaceAll(string(data), "\r\n", "\n")
+			lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+		}
+	}
+	if sourceFileCount.Add(1) > maxSourceFiles {
+		sourceFileCount.Add(-1)
+		return lines
+	}
+	v, loaded := sourceFiles.LoadOrStore(path, lines)
+	if loaded {
+		sourceFileCount.Add(-1)
+	}
+	return v.([]string)
+}
+
+// sourceFrames returns the frames of [Error.Frames] together with src,
+// the same frames with file paths as the runtime reports them so the
+// sources can be opened. Both come from one walk of the stack, so they
+// stay index-aligned even if the FramePolicy changes in between.
+func (e *Error) sourceFrames() (frames, src []Frame) {
+	if len(e.pcs) == 0 {
+		frames = parseTraces(e.remote)
+		return frames, frames
+	}
+	return collectFrames(e.pcs, true)
+}
+
+// sourceTraces is sourceFrames for %+v: the trace lines rendered from the
+// frames, ending with the truncation marker like [Error.Traces], and src
+// index-aligned with them. Traces itself is cached when first rendered,
+// so pairing it with a fresh walk could misplace snippets.
+func (e *Error) sourceTraces() (traces []string, src []Frame) {
+	if len(e.pcs) == 0 {
+		return e.remote, parseTraces(e.remote)
+	}
+	frames, src := e.sourceFrames()
+	if len(frames) == 0 && e.truncated == 0 {
+		return nil, nil
+	}
+	traces = make([]string, 0, len(frames)+1)
+	var buf []byte
+	for _, f := range frames {
+		buf = appendFrame(buf[:0], runtime.Frame{File: f.File, Line: f.Line, Function: f.Function})
+		traces = append(traces, string(buf))
+	}
+	if e.truncated > 0 {
+		traces = append(traces, truncationMarker(e.truncated))
+	}
+	return traces, src
+}
+
+// writeSnippet writes the n lines of source on each side of f's line, each
+// prefixed by indent and its line number, the frame's line marked by ">".
+// It writes nothing when the source is unavailable.
+func writeSnippet(w io.Writer, indent string, f Frame, n int) {
+	lines := sourceLines(f.File)
+	if f.Line < 1 || f.Line > len(lines) {
+		return
+	}
+	first, last := max(f.Line-n, 1), min(f.Line+n, len(lines))
+	width := len(strconv.Itoa(last))
+	var buf []byte
+	for i := first; i <= last; i++ {
+		buf = append(buf[:0], '\n')
+		buf = append(buf, indent...)
+		if i == f.Line {
+			buf = append(buf, "> "...)
+		} else {
+			buf = append(buf, "  "...)
+		}
+		num := strconv.Itoa(i)
+		for pad := len(num); pad < width; pad++ {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, num...)
+		buf = append(buf, " |"...)
+		if text := lines[i-1]; text != "" {
+			buf = append(buf, ' ')
+			buf = append(buf, text...)
+		}
+		w.Write(buf)
+	}
+}
@@ -0,0 +1,121 @@
+package aerr_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+func snippetErr() error {
+	return aerr.Code("SNIP").Message("snip failed").With("user_id", 7).With("table", "users").StackTrace().Err(nil) // snippet-origin
+}
+
+func TestSourceContextOffByDefault(t *testing.T) {
+	out := fmt.Sprintf("%+v", snippetErr())
+	if strings.Contains(out, "snippet-origin") {
+		t.Errorf("%%+v shows source with the option off:\n%s", out)
+	}
+}
+
+func TestSourceContextInDetailedFormat(t *testing.T) {
+	aerr.SetSourceContext(1)
+	defer aerr.SetSourceContext(0)
+
+	out := fmt.Sprintf("%+v", snippetErr())
+	lines := strings.Split(out, "\n")
+	for i, l := range lines {
+		if !strings.Contains(l, "snippet-origin") {
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(l), "> ") {
+			t.Errorf("failing line not marked: %q", l)
+		}
+		if i < 2 || !strings.Contains(lines[i-2], "source_test.go:") || !strings.Contains(lines[i-1], "func snippetErr() error {") {
+			t.Errorf("snippet does not follow its frame with one line before:\n%s", out)
+		}
+		if i+1 >= len(lines) || !strings.HasSuffix(lines[i+1], "| }") {
+			t.Errorf("snippet lacks the line after:\n%s", out)
+		}
+		return
+	}
+	t.Fatalf("no source snippet in:\n%s", out)
+}
+
+// TestSourceContextFollowsPolicyChange checks that each snippet stays under
+// its own frame when the FramePolicy changes after Traces was cached.
+func TestSourceContextFollowsPolicyChange(t *testing.T) {
+	err := snippetErr()
+	e, _ := aerr.AsAerr(err)
+	_ = e.Traces()
+
+	aerr.SetFramePolicy(&aerr.FramePolicy{Filter: func(f aerr.Frame) bool {
+		return !strings.HasSuffix(f.Function, ".snippetErr")
+	}})
+	defer aerr.SetFramePolicy(nil)
+	aerr.SetSourceContext(1)
+	defer aerr.SetSourceContext(0)
+
+	out := fmt.Sprintf("%+v", err)
+	if strings.Contains(out, "snippet-origin") {
+		t.Errorf("snippet of a filtered frame shown:\n%s", out)
+	}
+	_, trace, _ := strings.Cut(out, "stacktrace:\n")
+	if !strings.Contains(strings.SplitN(trace, "\n", 2)[0], "TestSourceContextFollowsPolicyChange") {
+		t.Errorf("first frame is not the test function:\n%s", out)
+	}
+}
+
+func TestSourceContextUnavailable(t *testing.T) {
+	aerr.SetSourceContext(3)
+	defer aerr.SetSourceContext(0)
+
+	for _, trace := range []string{
+		"/nonexistent/app/main.go:12 (main.main)",     // container without sources
+		"github.com/acme/app/main.go:12 (main.main)",  // -trimpath build
+		"/nonexistent/app/main.go:100000 (main.main)", // line past the end
+	} {
+		remote, err := aerr.ParseJSON([]byte(`{"code":"R","message":"m","stacktrace":["` + trace + `"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "m\ncode: R\nstacktrace:\n    " + trace
+		if got := fmt.Sprintf("%+v", remote); got != want {
+			t.Errorf("%%+v = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestWriteDev(t *testing.T) {
+	aerr.SetSourceContext(1)
+	defer aerr.SetSourceContext(0)
+
+	var buf bytes.Buffer
+	if err := aerr.WriteDev(&buf, fmt.Errorf("handler: %w", snippetErr())); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"[SNIP] handler: snip failed\n",
+		"    user_id = 7\n    table   = users\n",
+		"    at github.com/tafaquh/aerr_test.snippetErr\n       ",
+		"source_test.go:",
+		"> ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteDev output lacks %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(out, "snippet-origin") {
+		t.Errorf("WriteDev output lacks the source snippet:\n%s", out)
+	}
+
+	buf.Reset()
+	aerr.WriteDev(&buf, errors.New("plain"))
+	if buf.String() != "plain\n" {
+		t.Errorf("WriteDev(plain) = %q", buf.String())
+	}
+}
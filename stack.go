@@ -6,14 +6,55 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
+
+	"github.com/tafaquh/aerr/internal/funcname"
 )
 
-const stackMaxDepth = 32
+// DefaultStackDepth is the maximum number of frames a stack capture
+// records unless [SetStackDepth] or [Builder.StackTraceDepth] choose
+// another depth.
+const DefaultStackDepth = 32
+
+// stackDepth holds the process-global capture depth; zero means
+// DefaultStackDepth.
+var stackDepth atomic.Int32
+
+// SetStackDepth sets the process-global maximum number of frames captured
+// by builders that did not choose a depth with [Builder.StackTraceDepth].
+// n <= 0 restores [DefaultStackDepth].
+func SetStackDepth(n int) {
+	if n < 0 {
+		n = 0
+	}
+	stackDepth.Store(int32(n))
+}
+
+// StackTraceDepth enables stack capture like StackTrace, recording at most
+// n frames instead of the global depth (see [SetStackDepth]). n <= 0
+// restores the global depth.
+func (b *Builder) StackTraceDepth(n int) *Builder {
+	if n < 0 {
+		n = 0
+	}
+	b.captureStack = true
+	b.depth = n
+	return b
+}
+
+// stackDepthFor resolves the builder's effective capture depth.
+func (b *Builder) stackDepthFor() int {
+	if b.depth > 0 {
+		return b.depth
+	}
+	if n := int(stackDepth.Load()); n > 0 {
+		return n
+	}
+	return DefaultStackDepth
+}
 
-// selfPkgPrefix matches function names belonging to this package (e.g.
-// "github.com/tafaquh/aerr.(*Builder).Err"). The trailing dot keeps
-// subpackages and external test packages out of the match.
-const selfPkgPrefix = "github.com/tafaquh/aerr."
+// selfPkgPrefix matches function names belonging to this package.
+const selfPkgPrefix = funcname.SelfPrefix
 
 // stdlibDir is the directory containing the standard library sources as
 // reported by this binary's runtime, derived once from the location of a
@@ -26,7 +67,7 @@ const selfPkgPrefix = "github.com/tafaquh/aerr."
 // Under -trimpath the runtime reports stdlib files by their relative path
 // (e.g. "strings/strings.go"), so this anchor cannot be resolved and the
 // result is ""; skipFrame then classifies frames by function name instead
-// (see isStdlibFunc).
+// (see funcname.IsStdlib).
 var stdlibDir = func() string {
 	pc := reflect.ValueOf(strings.Contains).Pointer()
 	fn := runtime.FuncForPC(pc)
@@ -42,33 +83,87 @@ var stdlibDir = func() string {
 	return dir + "/"
 }()
 
-// captureStack collects PCs starting at the user-facing call site. skip
-// counts the frames between runtime.Callers and that call site, including
-// runtime.Callers itself and captureStack.
-func captureStack(skip int) []uintptr {
-	var pcs [stackMaxDepth]uintptr
-	n := runtime.Callers(skip, pcs[:])
+// captureStack collects at most depth PCs starting at the user-facing call
+// site. skip counts the frames between runtime.Callers and that call site,
+// including runtime.Callers itself and captureStack. When the stack is
+// deeper than depth, truncated reports how many of the frames left out
+// the installed FramePolicy would have rendered. Leading frames of
+// functions marked with Helper are dropped.
+func captureStack(skip, depth int) (pcs []uintptr, truncated int) {
+	var buf [DefaultStackDepth]uintptr
+	s := buf[:]
+	if depth > len(buf) {
+		s = make([]uintptr, depth)
+	}
+	s = s[:depth]
+	n := runtime.Callers(skip, s)
 	if n == 0 {
-		return nil
+		return nil, 0
+	}
+	if n == depth {
+		// The buffer filled up; count the rest without keeping it so the
+		// rendered trace can say how much is missing.
+		var probe [64]uintptr
+		var rest []uintptr
+		for off := skip + n; ; {
+			m := runtime.Callers(off, probe[:])
+			rest = append(rest, probe[:m]...)
+			off += m
+			if m < len(probe) {
+				break
+			}
+		}
+		truncated = keptFrames(rest)
 	}
 	out := make([]uintptr, n)
-	copy(out, pcs[:n])
-	return out
+	copy(out, s[:n])
+	return trimHelpers(out), truncated
+}
+
+// keptFrames counts the frames of pcs that renderTraces would keep, so a
+// truncation marker matches what the user would have seen.
+func keptFrames(pcs []uintptr) int {
+	if len(pcs) == 0 {
+		return 0
+	}
+	policy := framePolicy.Load()
+	frames := runtime.CallersFrames(pcs)
+	n := 0
+	for {
+		frame, more := frames.Next()
+		if !policy.drop(frame) {
+			n++
+		}
+		if !more {
+			return n
+		}
+	}
+}
+
+// truncationMarker is the final Traces entry of a truncated capture.
+func truncationMarker(n int) string {
+	if n == 1 {
+		return "... 1 more frame"
+	}
+	return "... " + strconv.Itoa(n) + " more frames"
 }
 
 // renderTraces converts raw PCs into "file:line (func)" strings, dropping
-// stdlib and aerr-internal frames so users only see their own code.
-// Returns nil when nothing remains.
-func renderTraces(pcs []uintptr) []string {
+// stdlib and aerr-internal frames so users only see their own code, or
+// applying the installed FramePolicy. When truncated is positive a final
+// truncationMarker entry is appended. Returns nil when nothing remains.
+func renderTraces(pcs []uintptr, truncated int) []string {
 	if len(pcs) == 0 {
 		return nil
 	}
+	policy := framePolicy.Load()
 	frames := runtime.CallersFrames(pcs)
-	out := make([]string, 0, len(pcs))
+	out := make([]string, 0, len(pcs)+1)
 	var buf []byte
 	for {
 		frame, more := frames.Next()
-		if !skipFrame(frame) {
+		if !policy.drop(frame) {
+			frame.File = policy.file(frame)
 			buf = appendFrame(buf[:0], frame)
 			out = append(out, string(buf))
 		}
@@ -76,6 +171,9 @@ func renderTraces(pcs []uintptr) []string {
 			break
 		}
 	}
+	if truncated > 0 {
+		out = append(out, truncationMarker(truncated))
+	}
 	if len(out) == 0 {
 		return nil
 	}
@@ -98,35 +196,8 @@ func skipFrame(f runtime.Frame) bool {
 	}
 	// Fallback when the stdlib anchor could not be resolved (a -trimpath
 	// build reports relative stdlib paths, so stdlibDir is ""). Classify
-	// by function name instead: package main is always user code, and a
-	// stdlib import path's first segment carries no dot.
-	if strings.HasPrefix(f.Function, "main.") {
-		return false
-	}
-	return isStdlibFunc(f.Function)
-}
-
-// isStdlibFunc classifies a frame by its fully qualified function name,
-// used only when stdlibDir could not be resolved (see stdlibDir). A
-// function name has the form "<import-path>.<func>", where the import
-// path may contain slashes and the func part may contain dots (methods
-// read "(*Type).Method"). The first path segment is the text up to the
-// first '/', or up to the first '.' when there is no slash. A stdlib
-// import path's first segment carries no dot ("runtime", "net", "encoding"
-// for net/http and encoding/json), whereas a module path opens with a
-// domain segment like "github.com".
-//
-// This name heuristic cannot tell a locally-developed module whose path
-// is a single dotless word (e.g. `module myapp`) from stdlib, so such a
-// module's frames are mis-dropped under -trimpath; give the module a
-// dotted or multi-segment path to keep them. package main is handled by
-// the caller and never reaches here.
-func isStdlibFunc(fn string) bool {
-	seg, _, hasSlash := strings.Cut(fn, "/")
-	if !hasSlash {
-		seg, _, _ = strings.Cut(fn, ".")
-	}
-	return !strings.Contains(seg, ".")
+	// by function name instead.
+	return funcname.Hidden(f.Function)
 }
 
 // Frame is one entry of a captured stack trace in structured form, for
@@ -143,28 +214,51 @@ type Frame struct {
 }
 
 // Frames returns the captured stack as structured frames, applying the
-// same user-code filtering as Traces. It returns nil when no stack was
-// captured. Unlike Traces the result is built on every call, so callers
-// should retain it rather than re-invoke in hot paths.
+// same filtering and path trimming as Traces (see [SetFramePolicy]). It
+// returns nil when no stack was captured. For an error decoded with
+// [ParseJSON] the frames are parsed back from the remote trace's
+// "file:line (function)" lines. Frames lists only real frames;
+// [Error.TruncatedFrames] reports how many the capture depth cut off,
+// which Traces renders as a final marker entry. Unlike Traces the result
+// is built on every call, so callers should retain it rather than
+// re-invoke in hot paths.
 func (e *Error) Frames() []Frame {
-	if e == nil || len(e.pcs) == 0 {
+	if e == nil {
 		return nil
 	}
-	frames := runtime.CallersFrames(e.pcs)
-	out := make([]Frame, 0, len(e.pcs))
+	if len(e.pcs) == 0 {
+		return parseTraces(e.remote)
+	}
+	frames, _ := collectFrames(e.pcs, false)
+	return frames
+}
+
+// collectFrames symbolizes pcs, keeping the frames the installed
+// FramePolicy keeps, with file paths trimmed by the policy. When withSrc
+// is set, src holds the same frames with file paths as the runtime
+// reports them, index-aligned with frames.
+func collectFrames(pcs []uintptr, withSrc bool) (frames, src []Frame) {
+	policy := framePolicy.Load()
+	it := runtime.CallersFrames(pcs)
+	frames = make([]Frame, 0, len(pcs))
 	for {
-		f, more := frames.Next()
-		if !skipFrame(f) {
-			out = append(out, Frame{File: f.File, Line: f.Line, Function: f.Function})
+		f, more := it.Next()
+		if !policy.drop(f) {
+			fr := Frame{File: f.File, Line: f.Line, Function: f.Function}
+			if withSrc {
+				src = append(src, fr)
+			}
+			fr.File = policy.file(f)
+			frames = append(frames, fr)
 		}
 		if !more {
 			break
 		}
 	}
-	if len(out) == 0 {
-		return nil
+	if len(frames) == 0 {
+		return nil, nil
 	}
-	return out
+	return frames, src
 }
 
 func appendFrame(buf []byte, f runtime.Frame) []byte {
@@ -176,3 +270,74 @@ func appendFrame(buf []byte, f runtime.Frame) []byte {
 	buf = append(buf, ')')
 	return buf
 }
+
+// parseTraces is the inverse of appendFrame for traces received from
+// another process. A line that does not parse keeps its whole text as
+// File, so no information is dropped.
+func parseTraces(traces []string) []Frame {
+	if len(traces) == 0 {
+		return nil
+	}
+	if _, ok := parseTruncationMarker(traces[len(traces)-1]); ok {
+		traces = traces[:len(traces)-1]
+		if len(traces) == 0 {
+			return nil
+		}
+	}
+	out := make([]Frame, len(traces))
+	for i, tr := range traces {
+		out[i] = Frame{File: tr}
+		// Function names never contain " (", file paths might.
+		sep := strings.LastIndex(tr, " (")
+		if sep < 0 || !strings.HasSuffix(tr, ")") {
+			continue
+		}
+		loc, fn := tr[:sep], tr[sep+2:]
+		colon := strings.LastIndexByte(loc, ':')
+		if colon < 0 {
+			continue
+		}
+		line, err := strconv.Atoi(loc[colon+1:])
+		if err != nil {
+			continue
+		}
+		out[i] = Frame{File: loc[:colon], Line: line, Function: strings.TrimSuffix(fn, ")")}
+	}
+	return out
+}
+
+// TruncatedFrames returns how many frames were left out of the captured
+// stack because it was deeper than the capture depth (see
+// [SetStackDepth]), or 0 when the trace is complete. Only frames the
+// installed [FramePolicy] would render are counted. For an error decoded
+// with [ParseJSON] it is read back from the remote trace's marker entry.
+func (e *Error) TruncatedFrames() int {
+	if e == nil {
+		return 0
+	}
+	if len(e.pcs) == 0 {
+		if len(e.remote) == 0 {
+			return 0
+		}
+		n, _ := parseTruncationMarker(e.remote[len(e.remote)-1])
+		return n
+	}
+	return e.truncated
+}
+
+// parseTruncationMarker is the inverse of truncationMarker.
+func parseTruncationMarker(s string) (int, bool) {
+	rest, ok := strings.CutPrefix(s, "... ")
+	if !ok {
+		return 0, false
+	}
+	num, unit, _ := strings.Cut(rest, " ")
+	if unit != "more frames" && unit != "more frame" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
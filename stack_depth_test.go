@@ -0,0 +1,137 @@
+package aerr_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// nest calls fn at the bottom of n extra frames.
+func nest(n int, fn func() error) error {
+	if n <= 0 {
+		return fn()
+	}
+	return nest(n-1, fn)
+}
+
+func TestStackTraceDepthTruncates(t *testing.T) {
+	err := nest(20, func() error {
+		return aerr.Code("DEEP").StackTraceDepth(4).Err(nil)
+	})
+	e, _ := aerr.AsAerr(err)
+	n := e.TruncatedFrames()
+	if n == 0 {
+		t.Fatal("TruncatedFrames() = 0 for a 4-frame capture 20 frames deep")
+	}
+	traces := e.Traces()
+	want := fmt.Sprintf("... %d more frames", n)
+	if last := traces[len(traces)-1]; last != want {
+		t.Errorf("last Traces() entry = %q, want %q", last, want)
+	}
+	if len(traces) > 5 {
+		t.Errorf("len(Traces()) = %d, want <= 4 frames + marker", len(traces))
+	}
+	for _, f := range e.Frames() {
+		if strings.HasPrefix(f.File, "...") {
+			t.Errorf("Frames() contains the truncation marker: %+v", f)
+		}
+	}
+	if len(e.Frames()) != len(traces)-1 {
+		t.Errorf("len(Frames()) = %d, want %d", len(e.Frames()), len(traces)-1)
+	}
+}
+
+// TestTruncationCountsRenderedFrames checks that the marker counts exactly
+// the frames a complete capture would have rendered, including when the
+// cut-off frames span several probe reads, and not the runtime and
+// testing frames the render drops.
+func TestTruncationCountsRenderedFrames(t *testing.T) {
+	for _, depth := range []int{2, aerr.DefaultStackDepth, 100} {
+		var full, cut *aerr.Error
+		_ = nest(150, func() error {
+			for _, d := range []int{4096, depth} {
+				e, _ := aerr.AsAerr(aerr.Code("DEEP").StackTraceDepth(d).Err(nil))
+				full, cut = cut, e
+			}
+			return nil
+		})
+		want := len(full.Frames()) - len(cut.Frames())
+		if got := cut.TruncatedFrames(); got != want {
+			t.Errorf("depth %d: TruncatedFrames() = %d, want %d", depth, got, want)
+		}
+	}
+}
+
+func TestStackTraceDepthComplete(t *testing.T) {
+	err := aerr.Message("m").StackTraceDepth(4096).Err(nil)
+	e, _ := aerr.AsAerr(err)
+	if n := e.TruncatedFrames(); n != 0 {
+		t.Errorf("TruncatedFrames() = %d, want 0 for a complete capture", n)
+	}
+	for _, tr := range e.Traces() {
+		if strings.HasPrefix(tr, "... ") {
+			t.Errorf("complete trace has a truncation marker: %q", tr)
+		}
+	}
+}
+
+func TestSetStackDepth(t *testing.T) {
+	aerr.SetStackDepth(3)
+	defer aerr.SetStackDepth(0)
+
+	capture := func() *aerr.Error {
+		e, _ := aerr.AsAerr(nest(10, func() error { return aerr.StackTrace().Err(nil) }))
+		return e
+	}
+	shallow := capture()
+	if shallow.TruncatedFrames() == 0 {
+		t.Fatal("SetStackDepth(3) did not truncate a 10-deep capture")
+	}
+	if len(shallow.Traces()) > 4 {
+		t.Errorf("len(Traces()) = %d, want <= 3 frames + marker", len(shallow.Traces()))
+	}
+
+	// A per-builder depth overrides the global one.
+	e, _ := aerr.AsAerr(nest(10, func() error { return aerr.Code("X").StackTraceDepth(4096).Err(nil) }))
+	if e.TruncatedFrames() != 0 {
+		t.Errorf("StackTraceDepth(4096) truncated under SetStackDepth(3): %d", e.TruncatedFrames())
+	}
+
+	aerr.SetStackDepth(-1)
+	if e := capture(); e.TruncatedFrames() != 0 {
+		t.Errorf("SetStackDepth(-1) should restore the default; truncated %d", e.TruncatedFrames())
+	}
+}
+
+func TestTruncationInheritedAndRendered(t *testing.T) {
+	inner := nest(10, func() error { return aerr.Code("DEEP").StackTraceDepth(2).Err(nil) })
+	err := aerr.Message("outer").StackTrace().Wrap(inner)
+	e, _ := aerr.AsAerr(err)
+	n := e.TruncatedFrames()
+	if n == 0 {
+		t.Fatal("wrapping dropped the inherited truncation count")
+	}
+	marker := fmt.Sprintf("... %d more frames", n)
+
+	data, _ := json.Marshal(err)
+	if !strings.Contains(string(data), `"`+marker+`"]`) {
+		t.Errorf("JSON stacktrace does not end with the marker: %s", data)
+	}
+	if s := fmt.Sprintf("%+v", err); !strings.Contains(s, marker) {
+		t.Errorf("%%+v output lacks the marker:\n%s", s)
+	}
+
+	decoded, perr := aerr.ParseJSON(data)
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	if got := decoded.TruncatedFrames(); got != n {
+		t.Errorf("decoded TruncatedFrames() = %d, want %d", got, n)
+	}
+	if got, want := len(decoded.Frames()), len(e.Frames()); got != want {
+		t.Errorf("decoded len(Frames()) = %d, want %d", got, want)
+	}
+}
@@ -117,7 +117,106 @@ func TestSkipFrame(t *testing.T) {
 func TestRenderTracesNilWhenAllFiltered(t *testing.T) {
 	// PCs resolving only to stdlib/aerr frames must render as nil, matching
 	// the Traces() doc ("nil when none was captured").
-	if got := renderTraces(nil); got != nil {
-		t.Errorf("renderTraces(nil) = %v, want nil", got)
+	if got := renderTraces(nil, 0); got != nil {
+		t.Errorf("renderTraces(nil, 0) = %v, want nil", got)
+	}
+}
+
+func TestMatchPackage(t *testing.T) {
+	prefixes := []string{"github.com/go-chi/chi", "example.com/fw/"}
+	cases := map[string]bool{
+		"github.com/go-chi/chi.(*Mux).ServeHTTP":      true,
+		"github.com/go-chi/chi/v5.(*Mux).ServeHTTP":   true,
+		"github.com/go-chi/chi/middleware.Logger":     true,
+		"github.com/go-chi/chizzle.Handle":            false,
+		"example.com/fw/router.Route":                 true,
+		"example.com/framework.Route":                 false,
+		"github.com/user/project/services.(*S).Serve": false,
+	}
+	for fn, want := range cases {
+		if got := matchPackage(prefixes, fn); got != want {
+			t.Errorf("matchPackage(%q) = %v, want %v", fn, got, want)
+		}
+	}
+	if matchPackage([]string{""}, "main.main") {
+		t.Error("an empty prefix must not match everything")
+	}
+}
+
+func TestFuncPackage(t *testing.T) {
+	cases := map[string]string{
+		"main.main": "main",
+		"github.com/user/project/services.(*Service).Handle": "github.com/user/project/services",
+		"github.com/user/project/v2/db.Query.func1":          "github.com/user/project/v2/db",
+		"gopkg.in/yaml%2ev3.Unmarshal":                       "gopkg.in/yaml.v3",
+		"net/http.(*conn).serve":                             "net/http",
+	}
+	for fn, want := range cases {
+		if got := funcPackage(fn); got != want {
+			t.Errorf("funcPackage(%q) = %q, want %q", fn, got, want)
+		}
+	}
+}
+
+func TestModuleRelative(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   string
+		file string
+		want string
+		ok   bool
+	}{
+		{
+			name: "main module package",
+			fn:   "github.com/tafaquh/aerr/http.WriteProblem",
+			file: "/build/src/aerr/http/problem.go",
+			want: "http/problem.go",
+			ok:   true,
+		},
+		{
+			name: "main module external test package",
+			fn:   "github.com/tafaquh/aerr_test.TestSomething",
+			file: "/build/src/aerr/aerr_test.go",
+			want: "aerr_test.go",
+			ok:   true,
+		},
+		{
+			name: "module cache dependency",
+			fn:   "github.com/go-chi/chi/v5.(*Mux).ServeHTTP",
+			file: "/home/u/go/pkg/mod/github.com/go-chi/chi/v5@v5.0.12/mux.go",
+			want: "github.com/go-chi/chi/v5@v5.0.12/mux.go",
+			ok:   true,
+		},
+		{
+			name: "vendored dependency",
+			fn:   "github.com/acme/lib.Do",
+			file: "/build/app/vendor/github.com/acme/lib/lib.go",
+			want: "github.com/acme/lib/lib.go",
+			ok:   true,
+		},
+		{
+			name: "trimpath main module",
+			fn:   "github.com/tafaquh/aerr/http.WriteProblem",
+			file: "github.com/tafaquh/aerr/http/problem.go",
+			want: "http/problem.go",
+			ok:   true,
+		},
+		{
+			name: "unrelated layout",
+			fn:   "github.com/acme/lib.Do",
+			file: "/somewhere/else/lib.go",
+			ok:   false,
+		},
+	}
+	if mainModulePath() != "github.com/tafaquh/aerr" {
+		t.Skipf("main module path %q: build info unavailable", mainModulePath())
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := moduleRelative(runtime.Frame{Function: tc.fn, File: tc.file})
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("moduleRelative(%q, %q) = %q, %v, want %q, %v", tc.fn, tc.file, got, ok, tc.want, tc.ok)
+			}
+		})
 	}
 }
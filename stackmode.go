@@ -0,0 +1,169 @@
+package aerr
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// StackMode selects how wrapping treats a stack trace request when the
+// wrapped chain already carries one.
+type StackMode int
+
+const (
+	// StackDeepest keeps only the deepest stack: an outer StackTrace() is
+	// a no-op when the wrapped chain has a trace. It is the default.
+	StackDeepest StackMode = iota
+	// StackPerLayer lets every layer that requests a stack keep its own
+	// capture next to the inherited one, for chains that cross goroutines
+	// (a worker's error returned to the caller that scheduled it). The
+	// deepest stack still backs Traces and Frames; the other captures are
+	// read with [Error.StackSections].
+	StackPerLayer
+)
+
+// stackMode holds the process-global StackMode.
+var stackMode atomic.Int32
+
+// SetStackMode installs the process-global stack mode.
+func SetStackMode(m StackMode) {
+	stackMode.Store(int32(m))
+}
+
+// StackSection is the stack trace captured by one layer of a chain.
+type StackSection struct {
+	// Code is the layer's code, inherited from the wrapped error when the
+	// layer set none.
+	Code string
+	// Message is the layer's own message fragment (see Layer.Message).
+	Message string
+	// Traces is the rendered trace, like Error.Traces. In every section
+	// but the first, frames at the bottom of the stack that repeat the
+	// previous section's are replaced by a final "... N frames in common"
+	// entry.
+	Traces []string
+}
+
+// sectionJSON is the encoding of one "layer_stacks" entry, shared by
+// MarshalJSON, UnmarshalJSON, and LogValue.
+type sectionJSON struct {
+	Code       string   `json:"code,omitempty"`
+	Message    string   `json:"message,omitempty"`
+	Stacktrace []string `json:"stacktrace"`
+}
+
+// sectionsJSON converts sections to their encoded form.
+func sectionsJSON(secs []StackSection) []sectionJSON {
+	out := make([]sectionJSON, len(secs))
+	for i, sec := range secs {
+		out[i] = sectionJSON{Code: sec.Code, Message: sec.Message, Stacktrace: sec.Traces}
+	}
+	return out
+}
+
+// layerStack is a stack captured by an outer layer under StackPerLayer.
+type layerStack struct {
+	code      string
+	msg       string
+	pcs       []uintptr
+	truncated int
+}
+
+// StackSections returns one section per captured stack, innermost first:
+// the deepest stack (the one Traces returns), followed by the stack of
+// each outer layer that captured its own under [StackPerLayer]. It
+// returns nil when no stack was captured. Under the default
+// [StackDeepest] there is at most one section. The result is computed on
+// first use and cached; callers must treat it as read-only.
+func (e *Error) StackSections() []StackSection {
+	if e == nil {
+		return nil
+	}
+	e.sectionsOnce.Do(func() {
+		e.sections = e.buildSections()
+	})
+	return e.sections
+}
+
+// layerSections returns the sections after the first, which renderers
+// emit under "layer_stacks" next to "stacktrace".
+func (e *Error) layerSections() []StackSection {
+	if len(e.layerStacks) == 0 && len(e.remoteLayers) == 0 {
+		return nil
+	}
+	secs := e.StackSections()
+	if len(secs) < 2 {
+		return nil
+	}
+	return secs[1:]
+}
+
+func (e *Error) buildSections() []StackSection {
+	traces := e.Traces()
+	if len(traces) == 0 && len(e.layerStacks) == 0 && len(e.remoteLayers) == 0 {
+		return nil
+	}
+	first := StackSection{Code: e.code, Message: e.own.msg, Traces: traces}
+	if in := originLayer(e); in != nil {
+		first.Code, first.Message = in.code, in.own.msg
+	}
+	out := make([]StackSection, 0, 1+len(e.remoteLayers)+len(e.layerStacks))
+	out = append(out, first)
+	out = append(out, e.remoteLayers...)
+	prev := traces
+	if n := len(e.remoteLayers); n > 0 {
+		prev = e.remoteLayers[n-1].Traces
+	}
+	for _, ls := range e.layerStacks {
+		full := renderTraces(ls.pcs, ls.truncated)
+		out = append(out, StackSection{
+			Code:    ls.code,
+			Message: ls.msg,
+			Traces:  collapseCommon(full, prev),
+		})
+		prev = full
+	}
+	return out
+}
+
+// originLayer returns the innermost layer of e's chain that carries the
+// deepest stack without any per-layer captures: the one that captured or
+// decoded it.
+func originLayer(e *Error) *Error {
+	var found *Error
+	walkLayers(e, func(l *Error) {
+		if l.hasStack() && len(l.layerStacks) == 0 && len(l.remoteLayers) == 0 {
+			found = l
+		}
+	})
+	return found
+}
+
+// collapseCommon replaces the frames at the bottom of traces that repeat
+// the bottom of prev with a "... N frames in common" entry. Traces ending
+// in a truncation marker are returned unchanged, since their bottom frames
+// are missing.
+func collapseCommon(traces, prev []string) []string {
+	if len(traces) == 0 || len(prev) == 0 {
+		return traces
+	}
+	if _, ok := parseTruncationMarker(traces[len(traces)-1]); ok {
+		return traces
+	}
+	if _, ok := parseTruncationMarker(prev[len(prev)-1]); ok {
+		return traces
+	}
+	n := 0
+	for n < len(traces)-1 && n < len(prev) &&
+		traces[len(traces)-1-n] == prev[len(prev)-1-n] {
+		n++
+	}
+	if n == 0 {
+		return traces
+	}
+	out := make([]string, len(traces)-n, len(traces)-n+1)
+	copy(out, traces)
+	if n == 1 {
+		return append(out, "... 1 frame in common")
+	}
+	return append(out, "... "+strconv.Itoa(n)+" frames in common")
+}
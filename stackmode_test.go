@@ -0,0 +1,145 @@
+package aerr_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tafaquh/aerr"
+)
+
+// workerErr issues an error with a stack on another goroutine, as a
+// worker pool would.
+func workerErr() error {
+	ch := make(chan error)
+	go func() {
+		ch <- aerr.Code("WORKER").Message("job failed").StackTrace().Err(nil)
+	}()
+	return <-ch
+}
+
+func smInner() error {
+	return aerr.Code("INNER").Message("inner").StackTrace().Err(nil)
+}
+
+// smScenario captures twice below one shared caller frame.
+func smScenario() error {
+	return aerr.Code("OUTER").Message("outer").StackTrace().Wrap(smInner())
+}
+
+func TestStackModeDeepestDefault(t *testing.T) {
+	e, _ := aerr.AsAerr(aerr.Message("caller").StackTrace().Wrap(workerErr()))
+	secs := e.StackSections()
+	if len(secs) != 1 {
+		t.Fatalf("len(StackSections()) = %d under StackDeepest, want 1", len(secs))
+	}
+	if secs[0].Code != "WORKER" || secs[0].Message != "job failed" {
+		t.Errorf("section = %q / %q, want the capturing layer", secs[0].Code, secs[0].Message)
+	}
+	data, _ := json.Marshal(e)
+	if strings.Contains(string(data), "layer_stacks") {
+		t.Errorf("layer_stacks emitted under StackDeepest: %s", data)
+	}
+}
+
+func TestStackModePerLayer(t *testing.T) {
+	aerr.SetStackMode(aerr.StackPerLayer)
+	defer aerr.SetStackMode(aerr.StackDeepest)
+
+	err := aerr.Message("caller").StackTrace().Wrap(workerErr())
+	e, _ := aerr.AsAerr(err)
+	secs := e.StackSections()
+	if len(secs) != 2 {
+		t.Fatalf("len(StackSections()) = %d, want worker + caller", len(secs))
+	}
+	if strings.Join(secs[0].Traces, "|") != strings.Join(e.Traces(), "|") {
+		t.Error("first section must be the deepest stack Traces returns")
+	}
+	if !strings.Contains(strings.Join(secs[0].Traces, "\n"), "workerErr") {
+		t.Errorf("worker section lacks the worker frames: %q", secs[0].Traces)
+	}
+	if secs[1].Code != "WORKER" || secs[1].Message != "caller" {
+		t.Errorf("caller section = %q / %q, want inherited code and own message", secs[1].Code, secs[1].Message)
+	}
+	if !strings.Contains(secs[1].Traces[0], "TestStackModePerLayer") {
+		t.Errorf("caller section starts at %q, want the caller", secs[1].Traces[0])
+	}
+	if l := aerr.Layers(err); !l[0].CapturedStack {
+		t.Error("Layers: the per-layer capture must report CapturedStack")
+	}
+
+	// A layer that does not request a stack adds no section.
+	e2, _ := aerr.AsAerr(aerr.Message("plain").Wrap(err))
+	if len(e2.StackSections()) != 2 {
+		t.Errorf("non-capturing layer changed the sections: %d", len(e2.StackSections()))
+	}
+}
+
+func TestStackModeCollapsesCommonFrames(t *testing.T) {
+	aerr.SetStackMode(aerr.StackPerLayer)
+	defer aerr.SetStackMode(aerr.StackDeepest)
+
+	e, _ := aerr.AsAerr(smScenario())
+	secs := e.StackSections()
+	if len(secs) != 2 {
+		t.Fatalf("len(StackSections()) = %d, want 2", len(secs))
+	}
+	outer := secs[1].Traces
+	if len(outer) != 2 {
+		t.Fatalf("outer section = %q, want smScenario plus the common-frames marker", outer)
+	}
+	if !strings.Contains(outer[0], "smScenario") {
+		t.Errorf("outer section starts at %q, want smScenario", outer[0])
+	}
+	if outer[1] != "... 1 frame in common" {
+		t.Errorf("outer section ends with %q, want the collapsed test frame", outer[1])
+	}
+}
+
+func TestStackModeRendered(t *testing.T) {
+	aerr.SetStackMode(aerr.StackPerLayer)
+	err := aerr.Code("API").Message("handler").StackTrace().Wrap(workerErr())
+	aerr.SetStackMode(aerr.StackDeepest)
+	e, _ := aerr.AsAerr(err)
+	caller := e.StackSections()[1]
+
+	detail := fmt.Sprintf("%+v", err)
+	if !strings.Contains(detail, "\nstacktrace (API: handler):\n    "+caller.Traces[0]) {
+		t.Errorf("%%+v lacks the layer section:\n%s", detail)
+	}
+
+	var lv any
+	for _, a := range e.LogValue().Group() {
+		if a.Key == "layer_stacks" {
+			lv = a.Value.Any()
+		}
+	}
+	if lv == nil {
+		t.Error("LogValue lacks layer_stacks")
+	}
+
+	data, _ := json.Marshal(err)
+	var wire struct {
+		LayerStacks []struct {
+			Code       string   `json:"code"`
+			Message    string   `json:"message"`
+			Stacktrace []string `json:"stacktrace"`
+		} `json:"layer_stacks"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if len(wire.LayerStacks) != 1 || wire.LayerStacks[0].Code != "API" || wire.LayerStacks[0].Stacktrace[0] != caller.Traces[0] {
+		t.Errorf("JSON layer_stacks = %+v", wire.LayerStacks)
+	}
+
+	decoded, perr := aerr.ParseJSON(data)
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	secs := decoded.StackSections()
+	if len(secs) != 2 || secs[1].Code != "API" || secs[1].Traces[0] != caller.Traces[0] {
+		t.Errorf("decoded sections = %+v", secs)
+	}
+}
@@ -12,11 +12,17 @@
 // with zap.Object instead:
 //
 //	logger.Error("request failed", zap.Object("err", aerrzap.Object(err)))
+//
+// To structure existing zap.Error calls without touching them, wrap the
+// logger's core with WrapCore.
 package aerrzap
 
 import (
 	"fmt"
+	"log/slog"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tafaquh/aerr"
@@ -25,18 +31,21 @@ import (
 )
 
 // Field renders err under the key "error". When err carries an
-// *aerr.Error anywhere in its chain the field is a nested object with
-// code, message, attributes, and stacktrace; otherwise it falls back to
-// zap.Error, so Field is always a safe drop-in replacement. A nil err
-// produces a no-op field, matching zap.Error's behavior.
+// *aerr.Error anywhere in its chain, or contributes a code or attributes
+// through aerr.Coder or aerr.Attributer, the field is a nested object with
+// code, message, attributes, and stacktrace (plus fingerprint when
+// enabled with aerr.FingerprintOptions.Emit, layer_stacks under
+// aerr.StackPerLayer, wrapped_at when a return trace was recorded,
+// diagnostics when a snapshot was attached, and stack_raw in place of
+// stacktrace under aerr.SetRawStacks), its keys named by aerr.SetSchema;
+// otherwise it falls back to zap.Error, so Field is always a safe drop-in
+// replacement. A nil err produces a no-op field, matching zap.Error's
+// behavior.
 func Field(err error) zap.Field {
 	if err == nil {
 		return zap.Skip()
 	}
-	// The e != nil guard tolerates aerr v1.0.0, whose AsAerr returns
-	// (nil, true) for a typed-nil *aerr.Error; falling through renders the
-	// value safely instead of as an empty object.
-	if e, ok := aerr.AsAerr(err); ok && e != nil {
+	if e, ok := aerr.From(err); ok {
 		return zap.Object("error", aerrMarshaler{e: e})
 	}
 	return zap.Error(err)
@@ -48,19 +57,48 @@ func Field(err error) zap.Field {
 //
 //	logger.Error("request failed", zap.Object("err", aerrzap.Object(err)))
 //
-// When err carries no *aerr.Error the object contains only the error
-// message.
+// When err carries no *aerr.Error and contributes no code or attributes
+// (see aerr.From) the object contains only the error message.
 func Object(err error) zapcore.ObjectMarshaler {
-	if e, ok := aerr.AsAerr(err); ok && e != nil {
+	if e, ok := aerr.From(err); ok {
 		return aerrMarshaler{e: e}
 	}
 	return plainMarshaler{err: err}
 }
 
+// Flat renders err as top-level fields with dotted keys, as produced by
+// aerr.FlatAttrs, for backends that index only top-level fields:
+//
+//	logger.Error("request failed", aerrzap.Flat(err, aerr.FlatOptions{}))
+//	// {"msg":"request failed","err.code":"NOT_FOUND","err.attributes.user_id":7,...}
+//
+// Values encode as in Field. A nil err produces a no-op field.
+func Flat(err error, o aerr.FlatOptions) zap.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	return zap.Inline(flatMarshaler(aerr.FlatAttrs(err, o)))
+}
+
+// flatMarshaler adds flattened attributes to the enclosing object.
+type flatMarshaler []slog.Attr
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (f flatMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, a := range f {
+		if err := addAttr(enc, a.Key, a.Value.Resolve().Any()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // aerrMarshaler renders an *aerr.Error directly into a zapcore encoder,
 // avoiding the map/reflection path of zap.Any.
 type aerrMarshaler struct {
 	e *aerr.Error
+	// noStack omits the stacktrace, which WrapCore moved into the entry.
+	noStack bool
 }
 
 // MarshalLogObject implements zapcore.ObjectMarshaler.
@@ -68,14 +106,18 @@ func (m aerrMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	if m.e == nil {
 		return nil
 	}
+	s := aerr.CurrentSchema()
 	if code := m.e.Code(); code != "" {
-		enc.AddString("code", code)
+		enc.AddString(s.Code, code)
 	}
 	if msg := m.e.Error(); msg != "" {
-		enc.AddString("message", msg)
+		enc.AddString(s.Message, msg)
+	}
+	if aerr.EmitsFingerprint() {
+		enc.AddString(s.Fingerprint, m.e.Fingerprint())
 	}
 	if m.e.NumAttrs() > 0 {
-		err := enc.AddObject("attributes", zapcore.ObjectMarshalerFunc(func(dict zapcore.ObjectEncoder) error {
+		err := enc.AddObject(s.Attributes, zapcore.ObjectMarshalerFunc(func(dict zapcore.ObjectEncoder) error {
 			var addErr error
 			m.e.RangeAttrs(func(k string, v any) bool {
 				if addErr = addAttr(dict, k, v); addErr != nil {
@@ -89,8 +131,16 @@ func (m aerrMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 			return err
 		}
 	}
-	if traces := m.e.Traces(); len(traces) > 0 {
-		err := enc.AddArray("stacktrace", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+	if m.noStack {
+		// The entry carries the stack.
+	} else if raw, ok := m.e.RawStack(); ok && aerr.EmitsRawStacks() {
+		if err := enc.AddObject("stack_raw", rawStackMarshaler(raw)); err != nil {
+			return err
+		}
+	} else if traces := m.e.Traces(); len(traces) > 0 && s.StackString {
+		enc.AddString(s.Stacktrace, strings.Join(traces, "\n"))
+	} else if len(traces) > 0 {
+		err := enc.AddArray(s.Stacktrace, zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
 			for i := 0; i < len(traces); i++ {
 				arr.AppendString(traces[i])
 			}
@@ -100,9 +150,118 @@ func (m aerrMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 			return err
 		}
 	}
+	if secs := m.e.StackSections(); len(secs) > 1 {
+		err := enc.AddArray(s.LayerStacks, zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			for _, sec := range secs[1:] {
+				if err := arr.AppendObject(sectionMarshaler(sec)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	if sites := m.e.WrappedAt(); len(sites) > 0 {
+		err := enc.AddArray(s.WrappedAt, zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			for i := 0; i < len(sites); i++ {
+				arr.AppendString(sites[i])
+			}
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	if d := m.e.Diagnostics(); d != nil {
+		if err := enc.AddObject(s.Diagnostics, diagnosticsMarshaler{d}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// diagnosticsMarshaler renders "diagnostics" with the keys and order of
+// the core renderers.
+type diagnosticsMarshaler struct {
+	d *aerr.Diagnostics
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (m diagnosticsMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	d := m.d
+	if d.GoroutineID != 0 {
+		enc.AddInt64("goroutine_id", d.GoroutineID)
+	}
+	enc.AddInt("num_goroutine", d.NumGoroutine)
+	enc.AddInt("gomaxprocs", d.GOMAXPROCS)
+	enc.AddUint64("heap_alloc_bytes", d.HeapAlloc)
+	enc.AddUint64("heap_objects", d.HeapObjects)
+	enc.AddUint64("heap_goal_bytes", d.HeapGoal)
+	enc.AddUint64("gc_cycles", d.GCCycles)
+	addNonEmpty(enc, "go_version", d.GoVersion)
+	addNonEmpty(enc, "module", d.Module)
+	addNonEmpty(enc, "module_version", d.ModuleVersion)
+	addNonEmpty(enc, "vcs_revision", d.VCSRevision)
+	if d.VCSModified {
+		enc.AddBool("vcs_modified", true)
+	}
+	addNonEmpty(enc, "goroutines", d.Goroutines)
+	return nil
+}
+
+// addNonEmpty adds a string field unless it is empty.
+func addNonEmpty(enc zapcore.ObjectEncoder, key, val string) {
+	if val != "" {
+		enc.AddString(key, val)
+	}
+}
+
+// rawStackMarshaler renders "stack_raw" under aerr.SetRawStacks.
+type rawStackMarshaler aerr.RawStack
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (r rawStackMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if r.BuildID != "" {
+		enc.AddString("build_id", r.BuildID)
+	}
+	enc.AddString("anchor", "0x"+strconv.FormatUint(uint64(r.Anchor), 16))
+	pcs := aerr.RawStack(r).HexPCs()
+	err := enc.AddArray("pcs", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, pc := range pcs {
+			arr.AppendString(pc)
+		}
+		return nil
+	}))
+	if err != nil {
+		return err
+	}
+	if r.Truncated > 0 {
+		enc.AddInt("truncated", r.Truncated)
+	}
+	return nil
+}
+
+// sectionMarshaler renders one entry of "layer_stacks".
+type sectionMarshaler aerr.StackSection
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (s sectionMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if s.Code != "" {
+		enc.AddString("code", s.Code)
+	}
+	if s.Message != "" {
+		enc.AddString("message", s.Message)
+	}
+	return enc.AddArray("stacktrace", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, fr := range s.Traces {
+			arr.AppendString(fr)
+		}
+		return nil
+	}))
+}
+
 // plainMarshaler renders a non-aerr error for Object.
 type plainMarshaler struct {
 	err error
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/tafaquh/aerr"
+	"github.com/tafaquh/aerr/internal/aerrtest"
 	aerrzap "github.com/tafaquh/aerr/zap"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -193,3 +194,38 @@ func TestFieldTypedNilAttrError(t *testing.T) {
 		t.Errorf("error.attributes.cause = %v, want %q", got, "<nil>")
 	}
 }
+
+func TestFieldRenderOptions(t *testing.T) {
+	for _, tc := range aerrtest.RenderCases() {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Err()
+			if tc.Setup != nil {
+				defer tc.Setup()()
+			}
+			logger, buf := newJSONLogger()
+			logger.Error("failed", aerrzap.Field(err))
+
+			obj, _ := decodeLine(t, buf)["error"].(map[string]any)
+			tc.Check(t, obj, err)
+		})
+	}
+}
+
+func TestFlatSpreadsTopLevelFields(t *testing.T) {
+	err := aerr.Code("NOT_FOUND").Message("no user").With("user_id", 7).With("token", aerr.Redact("t")).StackTrace().Err(nil)
+
+	logger, buf := newJSONLogger()
+	logger.Error("failed", aerrzap.Flat(err, aerr.FlatOptions{}))
+
+	e, _ := aerr.AsAerr(err)
+	line := decodeLine(t, buf)
+	if line["err.code"] != "NOT_FOUND" || line["err.message"] != "no user" || line["err.attributes.user_id"] != float64(7) {
+		t.Errorf("line = %v", line)
+	}
+	if line["err.attributes.token"] != aerr.RedactedText || line["err.stacktrace.0"] != e.Traces()[0] {
+		t.Errorf("line = %v", line)
+	}
+	if _, ok := line["error"]; ok {
+		t.Errorf("nested error emitted: %v", line)
+	}
+}
@@ -0,0 +1,180 @@
+package aerrzap
+
+import (
+	"strings"
+
+	"github.com/tafaquh/aerr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Option configures the Core returned by WrapCore.
+type Option func(*coreConfig)
+
+type coreConfig struct {
+	entryStack bool
+	codeKey    string
+}
+
+// EntryStack moves the trace of the first aerr error that has one, among
+// the fields added with With and then the entry's, into the entry's own
+// stack, which the encoder writes under its StacktraceKey, replacing any
+// stack zap captured at the log call (the aerr trace points at where the
+// error originated). The error's object then omits its stacktrace, so the
+// encoder config must set StacktraceKey, as zap's presets do: with it
+// empty the trace is not written at all. Under aerr.SetRawStacks the
+// object keeps its stack_raw and the entry is left alone, so the trace is
+// not symbolized when logged.
+func EntryStack() Option {
+	return func(c *coreConfig) { c.entryStack = true }
+}
+
+// LiftCode adds the code of the first aerr error, among the fields added
+// with With and then the entry's, as a top-level string field under key.
+// Fields built with Field and Object count as aerr errors here and for
+// EntryStack.
+func LiftCode(key string) Option {
+	return func(c *coreConfig) { c.codeKey = key }
+}
+
+// WrapCore returns a zapcore.Core that renders plain zap.Error and
+// zap.NamedError fields the way Field does: a field whose error carries an
+// *aerr.Error, or contributes through aerr.Coder or aerr.Attributer, is
+// rewritten into the structured object under its own key, and any other
+// error field passes through. Fields added with With are rewritten too.
+// The returned core accepts entries by level alone, like zapcore's own
+// cores, and hands them to the wrapped core's Write: to sample, or to keep
+// the levels of a tee's branches, apply WrapCore inside the sampler or to
+// each branch of the tee rather than around them. Install it once where
+// the logger is built:
+//
+//	logger := zap.New(aerrzap.WrapCore(core, aerrzap.LiftCode("error_code")))
+//	logger.Error("request failed", zap.Error(err)) // structured
+//
+// or on an existing logger with zap.WrapCore:
+//
+//	logger = logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+//		return aerrzap.WrapCore(c)
+//	}))
+func WrapCore(core zapcore.Core, opts ...Option) zapcore.Core {
+	c := &coreConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &aerrCore{Core: core, c: c}
+}
+
+// aerrCore rewrites aerr error fields before passing entries on.
+type aerrCore struct {
+	zapcore.Core
+	c *coreConfig
+	// first is the first aerr error among the fields added with With, for
+	// LiftCode; stack is the trace EntryStack took from them, if any.
+	first *aerr.Error
+	stack string
+}
+
+// With implements zapcore.Core. Under EntryStack the trace of the first
+// aerr error with one moves out of the added fields, to be set on every
+// entry the returned core writes.
+func (ac *aerrCore) With(fields []zapcore.Field) zapcore.Core {
+	rewritten, first := rewriteFields(fields)
+	c := &aerrCore{c: ac.c, first: ac.first, stack: ac.stack}
+	if c.first == nil {
+		c.first = first
+	}
+	if first != nil && c.stack == "" {
+		c.stack = ac.moveStack(rewritten)
+	}
+	c.Core = ac.Core.With(rewritten)
+	return c
+}
+
+// Check implements zapcore.Core.
+func (ac *aerrCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ac.Enabled(ent.Level) {
+		return ce.AddCore(ent, ac)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core, rewriting the fields and applying the
+// options before passing the entry to the wrapped core.
+func (ac *aerrCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	fields, first := rewriteFields(fields)
+	if ac.first != nil {
+		first = ac.first
+	}
+	if ac.stack != "" {
+		ent.Stack = ac.stack
+	} else if first != nil {
+		if stack := ac.moveStack(fields); stack != "" {
+			ent.Stack = stack
+		}
+	}
+	if key := ac.c.codeKey; key != "" && first != nil {
+		if code := first.Code(); code != "" {
+			fields = append(fields, zap.String(key, code))
+		}
+	}
+	return ac.Core.Write(ent, fields)
+}
+
+// moveStack returns the trace of the first aerr error in fields, already
+// rewritten, that has one, and replaces its field with one omitting the
+// trace. It returns "" and leaves fields alone when EntryStack is off or
+// raw stacks are logged.
+func (ac *aerrCore) moveStack(fields []zapcore.Field) string {
+	if !ac.c.entryStack || aerr.EmitsRawStacks() {
+		return ""
+	}
+	for i, f := range fields {
+		m, ok := f.Interface.(aerrMarshaler)
+		if !ok {
+			continue
+		}
+		if traces := m.e.Traces(); len(traces) > 0 {
+			m.noStack = true
+			fields[i] = zap.Object(f.Key, m)
+			return strings.Join(traces, "\n")
+		}
+	}
+	return ""
+}
+
+// rewriteFields returns fields with every aerr error field replaced by the
+// structured object, and the first aerr error among them, counting fields
+// built with Field and Object. fields is copied before the first
+// replacement, never modified.
+func rewriteFields(fields []zapcore.Field) ([]zapcore.Field, *aerr.Error) {
+	var first *aerr.Error
+	out := fields
+	for i, f := range fields {
+		var e *aerr.Error
+		switch f.Type {
+		case zapcore.ErrorType:
+			err, _ := f.Interface.(error)
+			if err == nil {
+				continue
+			}
+			var ok bool
+			if e, ok = aerr.From(err); !ok {
+				continue
+			}
+		case zapcore.ObjectMarshalerType:
+			m, ok := f.Interface.(aerrMarshaler)
+			if !ok || m.e == nil {
+				continue
+			}
+			e = m.e
+		default:
+			continue
+		}
+		if first == nil {
+			first = e
+			out = append(make([]zapcore.Field, 0, len(fields)+1), fields...)
+		}
+		out[i] = zap.Object(f.Key, aerrMarshaler{e: e})
+	}
+	return out, first
+}
@@ -0,0 +1,193 @@
+package aerrzap_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tafaquh/aerr"
+	aerrzap "github.com/tafaquh/aerr/zap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newWrappedLogger returns a logger whose core is wrapped by WrapCore,
+// writing one JSON line per entry into the returned buffer.
+func newWrappedLogger(opts ...aerrzap.Option) (*zap.Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := zapcore.NewCore(encoder, zapcore.AddSync(buf), zapcore.InfoLevel)
+	return zap.New(aerrzap.WrapCore(core, opts...)), buf
+}
+
+func TestWrapCoreStructuresErrorFields(t *testing.T) {
+	logger, buf := newWrappedLogger()
+	err := aerr.Code("NOT_FOUND").Message("no user").With("user_id", 7).Err(nil)
+	logger.Error("failed", zap.Error(err), zap.NamedError("cause", errors.New("plain")))
+
+	line := decodeLine(t, buf)
+	obj, _ := line["error"].(map[string]any)
+	attrs, _ := obj["attributes"].(map[string]any)
+	if obj["code"] != "NOT_FOUND" || obj["message"] != "no user" || attrs["user_id"] != float64(7) {
+		t.Errorf("error = %v", line["error"])
+	}
+	if line["cause"] != "plain" {
+		t.Errorf("cause = %v, want the plain message", line["cause"])
+	}
+}
+
+func TestWrapCoreRewritesWithFields(t *testing.T) {
+	logger, buf := newWrappedLogger()
+	logger.With(zap.Error(aerr.Code("CTX").Err(nil))).Error("failed")
+
+	obj, _ := decodeLine(t, buf)["error"].(map[string]any)
+	if obj["code"] != "CTX" {
+		t.Errorf("error = %v", obj)
+	}
+}
+
+func TestWrapCoreLiftCode(t *testing.T) {
+	logger, buf := newWrappedLogger(aerrzap.LiftCode("error_code"))
+	logger.Error("failed", zap.String("k", "v"), zap.Error(aerr.Code("DB").Wrap(aerr.Code("INNER").Err(nil))))
+	if line := decodeLine(t, buf); line["error_code"] != "DB" {
+		t.Errorf("error_code = %v, want DB", line["error_code"])
+	}
+
+	buf.Reset()
+	logger.Error("failed", aerrzap.Field(aerr.Code("FIELD").Err(nil)))
+	if line := decodeLine(t, buf); line["error_code"] != "FIELD" {
+		t.Errorf("error_code = %v, want FIELD from aerrzap.Field", line["error_code"])
+	}
+
+	buf.Reset()
+	logger.Error("failed", zap.Error(errors.New("plain")))
+	if _, ok := decodeLine(t, buf)["error_code"]; ok {
+		t.Error("error_code lifted from a plain error")
+	}
+}
+
+func TestWrapCoreEntryStack(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := zapcore.NewCore(encoder, zapcore.AddSync(buf), zapcore.InfoLevel)
+	logger := zap.New(aerrzap.WrapCore(core, aerrzap.EntryStack()), zap.AddStacktrace(zapcore.ErrorLevel))
+
+	err := aerr.Code("DB").StackTrace().Err(nil)
+	e, _ := aerr.AsAerr(err)
+	logger.Error("failed", zap.Error(err))
+
+	line := decodeLine(t, buf)
+	if line["stacktrace"] != strings.Join(e.Traces(), "\n") {
+		t.Errorf("stacktrace = %v, want the aerr trace", line["stacktrace"])
+	}
+	obj, _ := line["error"].(map[string]any)
+	if _, ok := obj["stacktrace"]; ok || obj["code"] != "DB" {
+		t.Errorf("error = %v, want no nested stacktrace", obj)
+	}
+}
+
+func TestWrapCoreRespectsLevel(t *testing.T) {
+	logger, buf := newWrappedLogger()
+	logger.Debug("hidden", zap.Error(aerr.Code("X").Err(nil)))
+	if buf.Len() != 0 {
+		t.Errorf("debug entry written: %s", buf.String())
+	}
+}
+
+func TestWrapCoreInsideSampler(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(zapcore.NewSamplerWithOptions(aerrzap.WrapCore(obs), time.Minute, 1, 0))
+	err := aerr.Code("NOISY").Err(nil)
+	for i := 0; i < 3; i++ {
+		logger.Info("repeated", zap.Error(err))
+	}
+
+	if logs.Len() != 1 {
+		t.Fatalf("sampled entries = %d, want 1", logs.Len())
+	}
+	if f := logs.All()[0].Context[0]; f.Type != zapcore.ObjectMarshalerType {
+		t.Errorf("error field type = %v, want the structured object", f.Type)
+	}
+}
+
+func TestWrapCoreTeeBranches(t *testing.T) {
+	errCore, errLogs := observer.New(zapcore.ErrorLevel)
+	debugCore, debugLogs := observer.New(zapcore.DebugLevel)
+	lift := aerrzap.LiftCode("error_code")
+	logger := zap.New(zapcore.NewTee(aerrzap.WrapCore(errCore, lift), aerrzap.WrapCore(debugCore, lift)))
+	logger.Info("lookup", zap.Error(aerr.Code("NOT_FOUND").Err(nil)))
+
+	if errLogs.Len() != 0 {
+		t.Errorf("info entry reached the error-level branch: %v", errLogs.All())
+	}
+	if debugLogs.Len() != 1 || debugLogs.All()[0].ContextMap()["error_code"] != "NOT_FOUND" {
+		t.Errorf("debug branch = %v, want one rewritten entry", debugLogs.All())
+	}
+}
+
+func TestWrapCoreWithFieldsCount(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(aerrzap.WrapCore(obs, aerrzap.LiftCode("error_code"), aerrzap.EntryStack()))
+	err := aerr.Code("CTX").StackTrace().Err(nil)
+	e, _ := aerr.AsAerr(err)
+	logger = logger.With(zap.Error(err))
+	logger.Error("first")
+	logger.Error("second", zap.Error(aerr.Code("OTHER").StackTrace().Err(nil)))
+
+	for _, entry := range logs.All() {
+		if entry.ContextMap()["error_code"] != "CTX" || entry.Stack != strings.Join(e.Traces(), "\n") {
+			t.Errorf("%s: error_code = %v, stack = %q, want those of the With error", entry.Message, entry.ContextMap()["error_code"], entry.Stack)
+		}
+	}
+}
+
+func TestWrapCoreEntryStackOmitsRawStack(t *testing.T) {
+	logger, buf := newWrappedLogger(aerrzap.EntryStack())
+	err := aerr.Code("DB").StackTrace().Err(nil)
+	logger = logger.With(zap.Error(err))
+	aerr.SetRawStacks(true)
+	defer aerr.SetRawStacks(false)
+	logger.Error("failed")
+
+	line := decodeLine(t, buf)
+	obj, _ := line["error"].(map[string]any)
+	if _, ok := obj["stack_raw"]; ok || line["stacktrace"] == nil {
+		t.Errorf("line = %v, want the trace only on the entry", line)
+	}
+}
+
+// failingCore accepts every entry and fails to write it.
+type failingCore struct{ zapcore.LevelEnabler }
+
+func (c failingCore) With([]zapcore.Field) zapcore.Core { return c }
+func (c failingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+func (failingCore) Write(zapcore.Entry, []zapcore.Field) error { return errors.New("disk full") }
+func (failingCore) Sync() error                                { return nil }
+
+func TestWrapCoreReportsWriteErrors(t *testing.T) {
+	var out bytes.Buffer
+	logger := zap.New(aerrzap.WrapCore(failingCore{zapcore.InfoLevel}), zap.ErrorOutput(zapcore.AddSync(&out)))
+	logger.Error("failed", zap.Error(aerr.Code("X").Err(nil)))
+
+	if got := out.String(); strings.Count(got, "write error") != 1 || !strings.HasSuffix(got, "write error: disk full\n") {
+		t.Errorf("error output = %q", got)
+	}
+}
+
+func TestWrapCoreEntryStackKeepsCallerAndFields(t *testing.T) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(aerrzap.WrapCore(obs, aerrzap.EntryStack()), zap.AddCaller())
+	err := aerr.Code("DB").StackTrace().Err(nil)
+	e, _ := aerr.AsAerr(err)
+	logger.Error("failed", zap.Error(err))
+
+	entry := logs.All()[0]
+	if !entry.Caller.Defined || entry.Stack != strings.Join(e.Traces(), "\n") {
+		t.Errorf("entry caller = %v, stack = %q", entry.Caller, entry.Stack)
+	}
+}
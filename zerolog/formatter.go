@@ -16,11 +16,17 @@
 // error explicitly with Object:
 //
 //	logger.Error().Object("err", aerrzerolog.Object(err)).Msg("failed")
+//
+// RegisterWith and ObjectWith take options for key names and stack
+// limits, and LiftCode adds the code of an event's aerr error as a
+// top-level field.
 package aerrzerolog
 
 import (
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -53,8 +59,9 @@ func errMessage(err error) (msg string) {
 }
 
 // Register installs aerr rendering into zerolog's process-wide
-// ErrorMarshalFunc. Errors that do not carry an *aerr.Error anywhere in
-// their chain are delegated to the marshal func that was active before
+// ErrorMarshalFunc. Errors that neither carry an *aerr.Error anywhere in
+// their chain nor contribute through aerr.Coder or aerr.Attributer are
+// delegated to the marshal func that was active before
 // Register was called, so aerr coexists with other error customizations
 // instead of silently replacing them.
 //
@@ -66,13 +73,19 @@ func errMessage(err error) (msg string) {
 // Register mutates zerolog package state; call it once from main, not
 // from library code.
 func Register() {
+	RegisterWith()
+}
+
+// RegisterWith is Register with options applied to every aerr error the
+// installed marshal func renders:
+//
+//	aerrzerolog.RegisterWith(aerrzerolog.MaxFrames(10), aerrzerolog.StackFieldName())
+func RegisterWith(opts ...Option) {
+	c := newConfig(opts)
 	prev := zerolog.ErrorMarshalFunc
 	zerolog.ErrorMarshalFunc = func(err error) any {
-		// The e != nil guards tolerate aerr v1.0.0, whose AsAerr returns
-		// (nil, true) for a typed-nil *aerr.Error; falling through renders the
-		// value safely instead of as an empty object.
-		if e, ok := aerr.AsAerr(err); ok && e != nil {
-			return aerrMarshaller{e: e}
+		if e, ok := aerr.From(err); ok {
+			return c.marshaller(e)
 		}
 		if prev != nil {
 			return prev(err)
@@ -86,22 +99,46 @@ func Register() {
 //
 //	logger.Error().Object("err", aerrzerolog.Object(err)).Msg("failed")
 //
-// When err carries no *aerr.Error the object contains only the error
-// message.
+// When err carries no *aerr.Error and contributes no code or attributes
+// (see aerr.From) the object contains only the error message.
 func Object(err error) zerolog.LogObjectMarshaler {
-	if e, ok := aerr.AsAerr(err); ok && e != nil {
-		return aerrMarshaller{e: e}
+	return ObjectWith(err)
+}
+
+// ObjectWith is Object with options applied.
+func ObjectWith(err error, opts ...Option) zerolog.LogObjectMarshaler {
+	if e, ok := aerr.From(err); ok {
+		return newConfig(opts).marshaller(e)
 	}
 	return plainMarshaller{err: err}
 }
 
+// Fields renders err as top-level key/value pairs with dotted keys, as
+// produced by aerr.FlatAttrs, for zerolog's Fields method and backends
+// that index only top-level fields:
+//
+//	logger.Error().Fields(aerrzerolog.Fields(err, aerr.FlatOptions{})).Msg("failed")
+//	// {"level":"error","err.code":"NOT_FOUND","err.attributes.user_id":7,...,"message":"failed"}
+//
+// The pairs keep the order of the nested rendering. A nil err renders as
+// no fields.
+func Fields(err error, o aerr.FlatOptions) []any {
+	attrs := aerr.FlatAttrs(err, o)
+	out := make([]any, 0, 2*len(attrs))
+	for _, a := range attrs {
+		out = append(out, a.Key, a.Value.Resolve().Any())
+	}
+	return out
+}
+
 // AerrMarshalFunc returns a zerolog.LogObjectMarshaler when err carries an
-// *aerr.Error somewhere in its chain. Non-aerr errors fall through to
-// zerolog's default handling. It is exported for callers composing their
+// *aerr.Error somewhere in its chain or contributes a code or attributes
+// through aerr.Coder or aerr.Attributer (see aerr.From). Other errors fall
+// through to zerolog's default handling. It is exported for callers composing their
 // own zerolog.ErrorMarshalFunc; most applications should call Register
 // instead.
 func AerrMarshalFunc(err error) any {
-	if e, ok := aerr.AsAerr(err); ok && e != nil {
+	if e, ok := aerr.From(err); ok {
 		return aerrMarshaller{e: e}
 	}
 	return err
@@ -124,33 +161,144 @@ func AerrStackMarshaler(err error) any {
 }
 
 // aerrMarshaller renders an *aerr.Error directly into a zerolog event,
-// avoiding the map/reflection path of zerolog.Event.Interface.
+// avoiding the map/reflection path of zerolog.Event.Interface. The
+// fingerprint key is added when aerr.FingerprintOptions.Emit is set,
+// layer_stacks under aerr.StackPerLayer, wrapped_at when a return trace
+// was recorded, diagnostics when a snapshot was attached, and stack_raw in
+// place of stacktrace under aerr.SetRawStacks. Keys follow
+// aerr.CurrentSchema. It holds only the error, so boxing it in an
+// interface does not allocate; options select optMarshaller instead.
 type aerrMarshaller struct {
 	e *aerr.Error
 }
 
 // MarshalZerologObject implements zerolog.LogObjectMarshaler.
 func (m aerrMarshaller) MarshalZerologObject(evt *zerolog.Event) {
-	if m.e == nil {
+	marshalError(evt, m.e, &defaultConfig)
+}
+
+// optMarshaller is aerrMarshaller with options.
+type optMarshaller struct {
+	e *aerr.Error
+	c *config
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler.
+func (m optMarshaller) MarshalZerologObject(evt *zerolog.Event) {
+	marshalError(evt, m.e, m.c)
+}
+
+// marshalError renders e into evt as configured by c.
+func marshalError(evt *zerolog.Event, e *aerr.Error, c *config) {
+	if e == nil {
 		return
 	}
-	if code := m.e.Code(); code != "" {
-		evt.Str("code", code)
+	s := c.schema()
+	if code := e.Code(); code != "" {
+		evt.Str(s.Code, code)
+		noteCode(evt, code)
 	}
-	if msg := m.e.Error(); msg != "" {
-		evt.Str("message", msg)
+	if msg := e.Error(); msg != "" {
+		evt.Str(s.Message, msg)
 	}
-	if m.e.NumAttrs() > 0 {
+	if aerr.EmitsFingerprint() {
+		evt.Str(s.Fingerprint, e.Fingerprint())
+	}
+	if e.NumAttrs() > 0 {
 		dict := zerolog.Dict()
-		m.e.RangeAttrs(func(k string, v any) bool {
+		e.RangeAttrs(func(k string, v any) bool {
 			appendAttr(dict, k, v)
 			return true
 		})
-		evt.Dict("attributes", dict)
+		evt.Dict(s.Attributes, dict)
+	}
+	if c.omitStack {
+		// Neither the trace nor the per-layer stacks.
+	} else if raw, ok := e.RawStack(); ok && aerr.EmitsRawStacks() {
+		evt.Object("stack_raw", rawStackMarshaller(raw.Limit(c.maxFrames)))
+	} else if traces := c.traces(e); len(traces) > 0 && s.StackString {
+		evt.Str(s.Stacktrace, strings.Join(traces, "\n"))
+	} else if len(traces) > 0 {
+		evt.Strs(s.Stacktrace, traces)
+	}
+	if secs := e.StackSections(); len(secs) > 1 && !c.omitStack {
+		arr := zerolog.Arr()
+		for _, sec := range secs[1:] {
+			arr.Object(sectionMarshaller(sec))
+		}
+		evt.Array(s.LayerStacks, arr)
+	}
+	if sites := e.WrappedAt(); len(sites) > 0 {
+		evt.Strs(s.WrappedAt, sites)
+	}
+	if d := e.Diagnostics(); d != nil {
+		evt.Object(s.Diagnostics, diagnosticsMarshaller{d})
+	}
+}
+
+// diagnosticsMarshaller renders "diagnostics" with the keys and order of
+// the core renderers.
+type diagnosticsMarshaller struct {
+	d *aerr.Diagnostics
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler.
+func (m diagnosticsMarshaller) MarshalZerologObject(evt *zerolog.Event) {
+	d := m.d
+	if d.GoroutineID != 0 {
+		evt.Int64("goroutine_id", d.GoroutineID)
+	}
+	evt.Int("num_goroutine", d.NumGoroutine).
+		Int("gomaxprocs", d.GOMAXPROCS).
+		Uint64("heap_alloc_bytes", d.HeapAlloc).
+		Uint64("heap_objects", d.HeapObjects).
+		Uint64("heap_goal_bytes", d.HeapGoal).
+		Uint64("gc_cycles", d.GCCycles)
+	for _, kv := range [...][2]string{
+		{"go_version", d.GoVersion},
+		{"module", d.Module},
+		{"module_version", d.ModuleVersion},
+		{"vcs_revision", d.VCSRevision},
+	} {
+		if kv[1] != "" {
+			evt.Str(kv[0], kv[1])
+		}
+	}
+	if d.VCSModified {
+		evt.Bool("vcs_modified", true)
+	}
+	if d.Goroutines != "" {
+		evt.Str("goroutines", d.Goroutines)
+	}
+}
+
+// rawStackMarshaller renders "stack_raw" under aerr.SetRawStacks.
+type rawStackMarshaller aerr.RawStack
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler.
+func (r rawStackMarshaller) MarshalZerologObject(evt *zerolog.Event) {
+	if r.BuildID != "" {
+		evt.Str("build_id", r.BuildID)
+	}
+	evt.Str("anchor", "0x"+strconv.FormatUint(uint64(r.Anchor), 16))
+	evt.Strs("pcs", aerr.RawStack(r).HexPCs())
+	if r.Truncated > 0 {
+		evt.Int("truncated", r.Truncated)
+	}
+}
+
+// sectionMarshaller renders one entry of "layer_stacks".
+type sectionMarshaller aerr.StackSection
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler.
+func (s sectionMarshaller) MarshalZerologObject(evt *zerolog.Event) {
+	if s.Code != "" {
+		evt.Str("code", s.Code)
 	}
-	if traces := m.e.Traces(); len(traces) > 0 {
-		evt.Strs("stacktrace", traces)
+	if s.Message != "" {
+		evt.Str("message", s.Message)
 	}
+	evt.Strs("stacktrace", s.Traces)
 }
 
 // plainMarshaller renders a non-aerr error for Object.
@@ -0,0 +1,187 @@
+package aerrzerolog
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/tafaquh/aerr"
+)
+
+// Option configures how RegisterWith and ObjectWith render aerr errors.
+type Option func(*config)
+
+type config struct {
+	keys       aerr.Schema
+	omitStack  bool
+	maxFrames  int
+	stackField bool
+}
+
+// defaultConfig renders like Register and Object.
+var defaultConfig config
+
+// newConfig applies opts to a fresh config, or returns defaultConfig when
+// there are none.
+func newConfig(opts []Option) *config {
+	if len(opts) == 0 {
+		return &defaultConfig
+	}
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// marshaller returns the marshaller rendering e as configured by c.
+func (c *config) marshaller(e *aerr.Error) zerolog.LogObjectMarshaler {
+	if c == &defaultConfig {
+		return aerrMarshaller{e: e}
+	}
+	return optMarshaller{e: e, c: c}
+}
+
+// Keys names the keys of the error object, overriding aerr.CurrentSchema
+// for this adapter; empty fields keep the current schema's key:
+//
+//	aerrzerolog.RegisterWith(aerrzerolog.Keys(aerr.Schema{Code: "kind", Stacktrace: "trace"}))
+//
+// Setting StackString renders the stack as one newline-separated string.
+func Keys(s aerr.Schema) Option {
+	return func(c *config) { c.keys = s }
+}
+
+// OmitStack leaves the stack trace out of the error object, along with
+// stack_raw and layer_stacks.
+func OmitStack() Option {
+	return func(c *config) { c.omitStack = true }
+}
+
+// MaxFrames caps the rendered stack trace at n frames; frames cut off are
+// counted in a final "... N more frames" entry like the one aerr writes
+// when the capture depth is hit. Under aerr.SetRawStacks it cuts the PCs
+// of stack_raw with aerr.RawStack.Limit, which counts the same frames.
+// n <= 0 means no cap.
+func MaxFrames(n int) Option {
+	return func(c *config) { c.maxFrames = n }
+}
+
+// StackFieldName names the stack trace key zerolog.ErrorStackFieldName
+// ("stack" by default), read at each render, so aerr traces sit under the
+// same key as the stacks zerolog's Stack() writes for other errors. It
+// takes precedence over the Stacktrace key of Keys.
+func StackFieldName() Option {
+	return func(c *config) { c.stackField = true }
+}
+
+// schema returns the keys to render with.
+func (c *config) schema() aerr.Schema {
+	s := aerr.CurrentSchema()
+	if c == &defaultConfig {
+		return s
+	}
+	for _, kv := range [...]struct {
+		key *string
+		val string
+	}{
+		{&s.Code, c.keys.Code},
+		{&s.Message, c.keys.Message},
+		{&s.Fingerprint, c.keys.Fingerprint},
+		{&s.Attributes, c.keys.Attributes},
+		{&s.Stacktrace, c.keys.Stacktrace},
+		{&s.LayerStacks, c.keys.LayerStacks},
+		{&s.WrappedAt, c.keys.WrappedAt},
+		{&s.Diagnostics, c.keys.Diagnostics},
+	} {
+		if kv.val != "" {
+			*kv.key = kv.val
+		}
+	}
+	s.StackString = s.StackString || c.keys.StackString
+	if c.stackField {
+		s.Stacktrace = zerolog.ErrorStackFieldName
+	}
+	return s
+}
+
+// traces returns e's rendered trace, capped at maxFrames.
+func (c *config) traces(e *aerr.Error) []string {
+	traces := e.Traces()
+	if c.maxFrames <= 0 || len(traces) <= c.maxFrames {
+		return traces
+	}
+	cut := e.TruncatedFrames()
+	if cut > 0 {
+		// The last entry is already a truncation marker.
+		traces = traces[:len(traces)-1]
+	}
+	if len(traces) <= c.maxFrames {
+		return e.Traces()
+	}
+	cut += len(traces) - c.maxFrames
+	return append(traces[:c.maxFrames:c.maxFrames], moreFrames(cut))
+}
+
+// moreFrames formats the truncation marker aerr uses.
+func moreFrames(n int) string {
+	if n == 1 {
+		return "... 1 more frame"
+	}
+	return "... " + strconv.Itoa(n) + " more frames"
+}
+
+// liftKey is the logger context key of the codeHook LiftCode installed.
+type liftKey struct{}
+
+// noteCode records code for the codeHook of evt's logger, if it has one,
+// unless an earlier error of the event already did.
+func noteCode(evt *zerolog.Event, code string) {
+	if h, ok := evt.GetCtx().Value(liftKey{}).(*codeHook); ok {
+		h.codes.LoadOrStore(evt, code)
+	}
+}
+
+// LiftCode returns a copy of l that adds the code of each event's first
+// aerr error as a top-level string field under key:
+//
+//	logger = aerrzerolog.LiftCode(logger, "error_code")
+//	logger.Error().Err(err).Msg("failed") // {"level":"error","error":{...},"error_code":"NOT_FOUND",...}
+//
+// It installs a zerolog.Hook and marks the logger's context so that only
+// its events note their code; the context keeps its values. It sees
+// errors rendered by this package on the event itself — through Err or
+// AnErr after Register, or Object — but not errors nested in a
+// zerolog.Dict or Arr, errors added to a logger's context with With, nor
+// events and child loggers given another context with Ctx.
+func LiftCode(l zerolog.Logger, key string) zerolog.Logger {
+	h := &codeHook{key: key}
+	ctx := context.WithValue(loggerCtx(l), liftKey{}, h)
+	return l.Hook(h).With().Ctx(ctx).Logger()
+}
+
+// loggerCtx returns the context of l's events. zerolog has no accessor
+// for it, so it reads it from an event that is never sent.
+func loggerCtx(l zerolog.Logger) context.Context {
+	l = l.Sample(nil).Level(zerolog.TraceLevel)
+	e := l.Log()
+	ctx := e.GetCtx()
+	e.Discard()
+	return ctx
+}
+
+// codeHook is the zerolog.Hook installed by LiftCode. codes maps each
+// pending event of its logger to the code noted for it; an event built
+// but never sent keeps its entry.
+type codeHook struct {
+	key   string
+	codes sync.Map
+}
+
+// Run implements zerolog.Hook.
+func (h *codeHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	if code, ok := h.codes.LoadAndDelete(e); ok {
+		e.Str(h.key, code.(string))
+	}
+}
@@ -0,0 +1,177 @@
+package aerrzerolog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/tafaquh/aerr"
+	aerrzerolog "github.com/tafaquh/aerr/zerolog"
+)
+
+// logObject logs obj under "error" and returns the decoded object.
+func logObject(t *testing.T, obj zerolog.LogObjectMarshaler) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	logger.Error().Object("error", obj).Msg("failed")
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	got, _ := line["error"].(map[string]any)
+	return got
+}
+
+func TestObjectWithKeys(t *testing.T) {
+	err := aerr.Code("C").Message("m").With("k", "v").StackTrace().Err(nil)
+	got := logObject(t, aerrzerolog.ObjectWith(err, aerrzerolog.Keys(aerr.Schema{Code: "kind", Attributes: "fields"})))
+	fields, _ := got["fields"].(map[string]any)
+	if got["kind"] != "C" || got["message"] != "m" || fields["k"] != "v" || got["stacktrace"] == nil {
+		t.Errorf("error = %v", got)
+	}
+}
+
+func TestObjectWithOmitStack(t *testing.T) {
+	aerr.SetStackMode(aerr.StackPerLayer)
+	err := aerr.Code("OUTER").StackTrace().Wrap(aerr.Code("INNER").StackTrace().Err(nil))
+	aerr.SetStackMode(aerr.StackDeepest)
+
+	got := logObject(t, aerrzerolog.ObjectWith(err, aerrzerolog.OmitStack()))
+	for _, key := range []string{"stacktrace", "layer_stacks"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("%s emitted with OmitStack: %v", key, got)
+		}
+	}
+	if got["code"] != "OUTER" {
+		t.Errorf("error = %v", got)
+	}
+}
+
+func TestObjectWithMaxFrames(t *testing.T) {
+	err := recurse(6)
+	e, _ := aerr.AsAerr(err)
+	if len(e.Traces()) < 4 {
+		t.Fatalf("trace too short to cap: %q", e.Traces())
+	}
+
+	got := logObject(t, aerrzerolog.ObjectWith(err, aerrzerolog.MaxFrames(2)))
+	frames, _ := got["stacktrace"].([]any)
+	want := len(e.Traces()) - 2
+	if len(frames) != 3 || frames[0] != e.Traces()[0] || frames[2] != fmt.Sprintf("... %d more frames", want) {
+		t.Errorf("stacktrace = %v, want 2 frames and a marker for %d", frames, want)
+	}
+}
+
+func TestObjectWithStackFieldName(t *testing.T) {
+	saved := zerolog.ErrorStackFieldName
+	defer func() { zerolog.ErrorStackFieldName = saved }()
+	zerolog.ErrorStackFieldName = "trace"
+
+	got := logObject(t, aerrzerolog.ObjectWith(aerr.Code("C").StackTrace().Err(nil), aerrzerolog.StackFieldName()))
+	if _, ok := got["trace"]; !ok {
+		t.Errorf("error = %v, want the stack under %q", got, "trace")
+	}
+}
+
+func TestRegisterWithOptions(t *testing.T) {
+	saved := zerolog.ErrorMarshalFunc
+	defer func() { zerolog.ErrorMarshalFunc = saved }()
+	aerrzerolog.RegisterWith(aerrzerolog.OmitStack())
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	logger.Error().Err(aerr.Code("C").StackTrace().Err(nil)).Msg("failed")
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	obj, _ := line["error"].(map[string]any)
+	if _, ok := obj["stacktrace"]; ok || obj["code"] != "C" {
+		t.Errorf("error = %v", obj)
+	}
+}
+
+func TestLiftCode(t *testing.T) {
+	var buf bytes.Buffer
+	logger := aerrzerolog.LiftCode(zerolog.New(&buf), "error_code")
+
+	decode := func() map[string]any {
+		t.Helper()
+		var line map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+		}
+		buf.Reset()
+		return line
+	}
+
+	logger.Error().Err(aerr.Code("OUTER").Wrap(aerr.Code("INNER").Err(nil))).AnErr("other", aerr.Code("SECOND").Err(nil)).Msg("failed")
+	if got := decode()["error_code"]; got != "OUTER" {
+		t.Errorf("error_code = %v, want OUTER", got)
+	}
+	logger.Error().Object("err", aerrzerolog.Object(aerr.Code("OBJ").Err(nil))).Msg("failed")
+	if got := decode()["error_code"]; got != "OBJ" {
+		t.Errorf("error_code = %v, want OBJ", got)
+	}
+	logger.Error().Err(errors.New("plain")).Msg("failed")
+	if _, ok := decode()["error_code"]; ok {
+		t.Error("error_code added for a plain error")
+	}
+
+	plain := zerolog.New(&buf)
+	plain.Error().Err(aerr.Code("UNHOOKED").Err(nil)).Msg("failed")
+	if _, ok := decode()["error_code"]; ok {
+		t.Error("error_code added by a logger without LiftCode")
+	}
+}
+
+// ctxKey is a user's context key.
+type ctxKey struct{}
+
+func TestLiftCodeKeepsContext(t *testing.T) {
+	var seen []any
+	hook := zerolog.HookFunc(func(e *zerolog.Event, _ zerolog.Level, _ string) {
+		seen = append(seen, e.GetCtx().Value(ctxKey{}))
+	})
+	var buf bytes.Buffer
+	ctx := context.WithValue(context.Background(), ctxKey{}, "logger")
+	logger := aerrzerolog.LiftCode(zerolog.New(&buf).Hook(hook).With().Ctx(ctx).Logger(), "error_code")
+	logger.Error().Err(aerr.Code("C").Err(nil)).Msg("failed")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if line["error_code"] != "C" || len(seen) != 1 || seen[0] != "logger" {
+		t.Errorf("line = %v, context values = %v", line, seen)
+	}
+}
+
+func TestObjectWithMaxFramesRawStack(t *testing.T) {
+	aerr.SetRawStacks(true)
+	defer aerr.SetRawStacks(false)
+	err := recurse(6)
+	e, _ := aerr.AsAerr(err)
+	raw, _ := e.RawStack()
+
+	got := logObject(t, aerrzerolog.ObjectWith(err, aerrzerolog.MaxFrames(2)))
+	stack, _ := got["stack_raw"].(map[string]any)
+	pcs, _ := stack["pcs"].([]any)
+	want := raw.Limit(2)
+	if len(pcs) != len(want.PCs) || stack["truncated"] != float64(want.Truncated) || want.Truncated != len(e.Traces())-2 {
+		t.Errorf("stack_raw = %v, want %d PCs and %d truncated", stack, len(want.PCs), len(e.Traces())-2)
+	}
+}
+
+// recurse returns an error captured n calls deep.
+func recurse(n int) error {
+	if n == 0 {
+		return aerr.Code("DEEP").StackTrace().Err(nil)
+	}
+	return recurse(n - 1)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/tafaquh/aerr"
+	"github.com/tafaquh/aerr/internal/aerrtest"
 	aerrzerolog "github.com/tafaquh/aerr/zerolog"
 )
 
@@ -202,3 +203,47 @@ func TestZerologNonAerrDefault(t *testing.T) {
 		t.Errorf("non-aerr error lost its message:\n%s", buf.String())
 	}
 }
+
+func TestZerologRenderOptions(t *testing.T) {
+	for _, tc := range aerrtest.RenderCases() {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Err()
+			if tc.Setup != nil {
+				defer tc.Setup()()
+			}
+			var buf bytes.Buffer
+			logger := zerolog.New(&buf)
+			logger.Error().Err(err).Msg("failed")
+
+			var line map[string]any
+			if jerr := json.Unmarshal(buf.Bytes(), &line); jerr != nil {
+				t.Fatalf("invalid JSON: %v\n%s", jerr, buf.String())
+			}
+			obj, _ := line["error"].(map[string]any)
+			tc.Check(t, obj, err)
+		})
+	}
+}
+
+func TestFieldsSpreadTopLevel(t *testing.T) {
+	err := aerr.Code("NOT_FOUND").Message("no user").With("user_id", 7).With("token", aerr.Redact("t")).StackTrace().Err(nil)
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	logger.Error().Fields(aerrzerolog.Fields(err, aerr.FlatOptions{Prefix: "error", Separator: "_"})).Msg("failed")
+
+	e, _ := aerr.AsAerr(err)
+	var line map[string]any
+	if jerr := json.Unmarshal(buf.Bytes(), &line); jerr != nil {
+		t.Fatalf("invalid JSON: %v\n%s", jerr, buf.String())
+	}
+	if line["error_code"] != "NOT_FOUND" || line["error_message"] != "no user" || line["error_attributes_user_id"] != float64(7) {
+		t.Errorf("line = %v", line)
+	}
+	if line["error_attributes_token"] != aerr.RedactedText || line["error_stacktrace_0"] != e.Traces()[0] {
+		t.Errorf("line = %v", line)
+	}
+	if got := aerrzerolog.Fields(nil, aerr.FlatOptions{}); len(got) != 0 {
+		t.Errorf("Fields(nil) = %v, want none", got)
+	}
+}